@@ -15,10 +15,13 @@ import (
 
 // AppConfig is the main application configuration structure.
 type AppConfig struct {
-	Server   ServerConfig   `toml:"server"`
-	Network  NetworkConfig  `toml:"network"`
-	Media    MediaConfig    `toml:"media"`
-	Database DatabaseConfig `toml:"database"`
+	Server    ServerConfig    `toml:"server"`
+	Network   NetworkConfig   `toml:"network"`
+	Media     MediaConfig     `toml:"media"`
+	Database  DatabaseConfig  `toml:"database"`
+	Transcode TranscodeConfig `toml:"transcode"`
+	Hls       HlsConfig       `toml:"hls"`
+	Thumbnail ThumbnailConfig `toml:"thumbnail"`
 }
 
 // ServerConfig holds server settings.
@@ -28,23 +31,77 @@ type ServerConfig struct {
 	Name      string  `toml:"name"`
 	UUID      string  `toml:"uuid"`
 	IP        *string `toml:"ip"`
+
+	// AuthMode selects how incoming requests are authenticated: "none" (the
+	// default), "basic" (HTTP Basic auth against BasicAuthUsers), or
+	// "reverse-proxy" (trust a username header set by an upstream proxy).
+	AuthMode AuthMode `toml:"auth_mode"`
+
+	// BasicAuthUsers maps username to password for AuthMode "basic". Stored
+	// in plaintext in the config file, consistent with this being a
+	// self-hosted single-operator server rather than a multi-tenant one.
+	BasicAuthUsers map[string]string `toml:"basic_auth_users"`
+
+	// TrustedProxies lists CIDRs allowed to set UserHeader for AuthMode
+	// "reverse-proxy"; requests from any other peer are rejected rather than
+	// risk a client spoofing the header itself. The peer is the connection's
+	// TCP remote address; X-Forwarded-For and similar headers are not used.
+	TrustedProxies []string `toml:"trusted_proxies"`
+
+	// UserHeader is the header a trusted reverse proxy sets with the
+	// authenticated username, e.g. "Remote-User".
+	UserHeader string `toml:"user_header"`
 }
 
+// AuthMode selects how the web/SOAP server authenticates requests.
+type AuthMode string
+
+const (
+	AuthModeNone         AuthMode = "none"
+	AuthModeBasic        AuthMode = "basic"
+	AuthModeReverseProxy AuthMode = "reverse-proxy"
+)
+
 // NetworkConfig holds network settings.
 type NetworkConfig struct {
-	InterfaceSelection      string `toml:"interface_selection"` // Auto, All, or specific name
-	MulticastTTL            uint8  `toml:"multicast_ttl"`
-	AnnounceIntervalSeconds uint64 `toml:"announce_interval_seconds"`
+	InterfaceSelection      string   `toml:"interface_selection"` // Auto, All, or specific name
+	Interfaces              []string `toml:"interfaces"`          // SSDP bind list; empty selects every non-virtual interface
+	MulticastTTL            uint8    `toml:"multicast_ttl"`
+	AnnounceIntervalSeconds uint64   `toml:"announce_interval_seconds"`
 }
 
 // MediaConfig holds media library settings.
 type MediaConfig struct {
 	Directories         []MonitoredDirectoryConfig `toml:"directories"`
+	Roots               []MediaRoot                `toml:"roots"`
 	ScanOnStartup       bool                       `toml:"scan_on_startup"`
 	WatchForChanges     bool                       `toml:"watch_for_changes"`
 	CleanupDeletedFiles bool                       `toml:"cleanup_deleted_files"`
 	AutoplayEnabled     bool                       `toml:"autoplay_enabled"`
 	SupportedExtensions []string                   `toml:"supported_extensions"`
+
+	// DebounceSeconds is how long the watcher waits after a path's last
+	// fsnotify event before syncing it, coalescing create/write/rename
+	// bursts (e.g. a client that creates then moves a file) into one sync.
+	DebounceSeconds float64 `toml:"debounce_seconds"`
+
+	// SafeguardEventThreshold and SafeguardWindowSeconds bound a rolling
+	// window of fsnotify events. If more than SafeguardEventThreshold
+	// events arrive within SafeguardWindowSeconds (e.g. a rename storm on a
+	// flaky network mount), the watcher stops processing individual events
+	// and falls back to periodic full rescans until the rate subsides.
+	SafeguardEventThreshold int     `toml:"safeguard_event_threshold"`
+	SafeguardWindowSeconds  float64 `toml:"safeguard_window_seconds"`
+}
+
+// MediaRoot is a named root directory scoped to one or more DLNA media
+// types, letting a library be split across several physical locations
+// (e.g. a dedicated drive for video, another for music) instead of being
+// synthesized from a single primary directory.
+type MediaRoot struct {
+	Name       string   `toml:"name"`
+	Path       string   `toml:"path"`
+	MediaTypes []string `toml:"media_types"`
 }
 
 // MonitoredDirectoryConfig holds settings for a single media directory.
@@ -62,6 +119,42 @@ type DatabaseConfig struct {
 	BackupEnabled     bool    `toml:"backup_enabled"`
 }
 
+// TranscodeConfig holds on-the-fly transcoding settings.
+type TranscodeConfig struct {
+	Enabled        bool   `toml:"enabled"`
+	CacheDir       string `toml:"cache_dir"`
+	MaxCacheSizeMB int64  `toml:"max_cache_size_mb"`
+
+	// PreferredAccel selects the hardware acceleration ffmpeg should use:
+	// "auto" (use VAAPI if its device node opened at startup, otherwise
+	// software encoding), "none", or one
+	// of "vaapi", "qsv", "nvenc", "videotoolbox" to force a specific backend
+	// even if VuIO couldn't confirm it works.
+	PreferredAccel string `toml:"preferred_accel"`
+
+	// VaapiDevice is the VAAPI render node ffmpeg should target (e.g.
+	// "/dev/dri/renderD128"). Only relevant when PreferredAccel resolves to
+	// "vaapi".
+	VaapiDevice string `toml:"vaapi_device"`
+}
+
+// HlsConfig holds on-demand HLS segmenting settings.
+type HlsConfig struct {
+	Enabled            bool   `toml:"enabled"`
+	CacheDir           string `toml:"cache_dir"`
+	SessionIdleSeconds int64  `toml:"session_idle_seconds"`
+}
+
+// ThumbnailConfig holds on-demand thumbnail generation settings.
+type ThumbnailConfig struct {
+	Enabled  bool   `toml:"enabled"`
+	CacheDir string `toml:"cache_dir"`
+
+	// SizePx is the side length, in pixels, of the square JPEG thumbnails
+	// served from /thumb/{id}.
+	SizePx int `toml:"size_px"`
+}
+
 // Initialize loads the configuration from a file or creates a default one.
 func Initialize(configPath string, args []string) (*AppConfig, error) {
 	// For simplicity, we prioritize config file over CLI args if both are present.
@@ -108,6 +201,7 @@ func Default() *AppConfig {
 			Name:      fmt.Sprintf("VuIO Go (%s)", hostname),
 			UUID:      uuid.New().String(),
 			IP:        nil,
+			AuthMode:  AuthModeNone,
 		},
 		Network: NetworkConfig{
 			InterfaceSelection:      "Auto",
@@ -122,17 +216,37 @@ func Default() *AppConfig {
 					ExcludePatterns: plat.DefaultExcludePatterns,
 				},
 			},
-			ScanOnStartup:       true,
-			WatchForChanges:     true,
-			CleanupDeletedFiles: true,
-			AutoplayEnabled:     true,
-			SupportedExtensions: plat.DefaultMediaExtensions,
+			ScanOnStartup:           true,
+			WatchForChanges:         true,
+			CleanupDeletedFiles:     true,
+			AutoplayEnabled:         true,
+			SupportedExtensions:     plat.DefaultMediaExtensions,
+			DebounceSeconds:         2,
+			SafeguardEventThreshold: 500,
+			SafeguardWindowSeconds:  10,
 		},
 		Database: DatabaseConfig{
 			Path:              &plat.DatabasePath,
 			VacuumOnStartup:   false,
 			BackupEnabled:     true,
 		},
+		Transcode: TranscodeConfig{
+			Enabled:        true,
+			CacheDir:       filepath.Join(plat.ConfigDir, "transcode_cache"),
+			MaxCacheSizeMB: 2048,
+			PreferredAccel: "auto",
+			VaapiDevice:    "/dev/dri/renderD128",
+		},
+		Hls: HlsConfig{
+			Enabled:            true,
+			CacheDir:           filepath.Join(plat.ConfigDir, "hls_cache"),
+			SessionIdleSeconds: 300,
+		},
+		Thumbnail: ThumbnailConfig{
+			Enabled:  true,
+			CacheDir: filepath.Join(plat.ConfigDir, "thumbnail_cache"),
+			SizePx:   160,
+		},
 	}
 }
 
@@ -174,6 +288,27 @@ func (c *AppConfig) Validate() error {
 	if _, err := uuid.Parse(c.Server.UUID); err != nil {
 		return fmt.Errorf("invalid server UUID: %w", err)
 	}
+	switch c.Server.AuthMode {
+	case "", AuthModeNone:
+	case AuthModeBasic:
+		if len(c.Server.BasicAuthUsers) == 0 {
+			return fmt.Errorf("auth_mode %q requires at least one entry in basic_auth_users", AuthModeBasic)
+		}
+	case AuthModeReverseProxy:
+		if c.Server.UserHeader == "" {
+			return fmt.Errorf("auth_mode %q requires user_header", AuthModeReverseProxy)
+		}
+		if len(c.Server.TrustedProxies) == 0 {
+			return fmt.Errorf("auth_mode %q requires at least one entry in trusted_proxies", AuthModeReverseProxy)
+		}
+	default:
+		return fmt.Errorf("invalid auth_mode %q", c.Server.AuthMode)
+	}
+	switch c.Transcode.PreferredAccel {
+	case "", "auto", "none", "vaapi", "qsv", "nvenc", "videotoolbox":
+	default:
+		return fmt.Errorf("invalid transcode preferred_accel %q", c.Transcode.PreferredAccel)
+	}
 	if len(c.Media.Directories) == 0 {
 		slog.Warn("no media directories configured")
 	}
@@ -193,10 +328,35 @@ func (c *AppConfig) GetDatabasePath() string {
 	return platform.GetPlatformConfig().DatabasePath
 }
 
-// GetPrimaryMediaDir returns the first configured media directory.
-func (c *AppConfig) GetPrimaryMediaDir() string {
-	if len(c.Media.Directories) > 0 {
-		return c.Media.Directories[0].Path
+// GetMediaRoots returns the configured media roots. If none are configured
+// explicitly, one root covering every media type is derived per monitored
+// directory, preserving the historical behavior of a single shared library.
+func (c *AppConfig) GetMediaRoots() []MediaRoot {
+	if len(c.Media.Roots) > 0 {
+		return c.Media.Roots
+	}
+	roots := make([]MediaRoot, 0, len(c.Media.Directories))
+	for _, dir := range c.Media.Directories {
+		roots = append(roots, MediaRoot{
+			Name:       filepath.Base(dir.Path),
+			Path:       dir.Path,
+			MediaTypes: []string{"video", "audio", "image"},
+		})
+	}
+	return roots
+}
+
+// GetMediaRootsForType returns the configured media roots that serve the
+// given DLNA media type ("video", "audio", or "image"), in configured order.
+func (c *AppConfig) GetMediaRootsForType(mediaType string) []MediaRoot {
+	var matches []MediaRoot
+	for _, root := range c.GetMediaRoots() {
+		for _, t := range root.MediaTypes {
+			if t == mediaType {
+				matches = append(matches, root)
+				break
+			}
+		}
 	}
-	return platform.GetPlatformConfig().DefaultMediaDir
+	return matches
 }
\ No newline at end of file
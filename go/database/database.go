@@ -9,34 +9,65 @@ import (
 	"time"
 
 	"github.com/jmoiron/sqlx" // Corrected import path
-	_ "github.com/mattn/go-sqlite3"
+	_ "modernc.org/sqlite"
 )
 
 // MediaFile represents a media file in the database.
 type MediaFile struct {
-	ID          int64          `db:"id"`
-	Path        string         `db:"path"`
-	ParentPath  string         `db:"parent_path"`
-	Filename    string         `db:"filename"`
-	Size        int64          `db:"size"`
-	Modified    time.Time      `db:"modified"`
-	MimeType    string         `db:"mime_type"`
-	Duration    sql.NullInt64  `db:"duration"` // in milliseconds
-	Title       sql.NullString `db:"title"`
-	Artist      sql.NullString `db:"artist"`
-	Album       sql.NullString `db:"album"`
-	Genre       sql.NullString `db:"genre"`
-	TrackNumber sql.NullInt32  `db:"track_number"`
-	Year        sql.NullInt32  `db:"year"`
-	AlbumArtist sql.NullString `db:"album_artist"`
-	CreatedAt   time.Time      `db:"created_at"`
-	UpdatedAt   time.Time      `db:"updated_at"`
+	ID          int64           `db:"id"`
+	Path        string          `db:"path"`
+	ParentPath  string          `db:"parent_path"`
+	Filename    string          `db:"filename"`
+	Size        int64           `db:"size"`
+	Modified    time.Time       `db:"modified"`
+	MimeType    string          `db:"mime_type"`
+	Duration    sql.NullInt64   `db:"duration"` // in milliseconds
+	Title       sql.NullString  `db:"title"`
+	Artist      sql.NullString  `db:"artist"`
+	Album       sql.NullString  `db:"album"`
+	Genre       sql.NullString  `db:"genre"`
+	TrackNumber sql.NullInt32   `db:"track_number"`
+	Year        sql.NullInt32   `db:"year"`
+	AlbumArtist sql.NullString  `db:"album_artist"`
+	Disc        sql.NullInt32   `db:"disc"`
+	Width       sql.NullInt32   `db:"width"`
+	Height      sql.NullInt32   `db:"height"`
+	Bitrate     sql.NullInt32   `db:"bitrate"` // in kbps
+	Channels    sql.NullInt32   `db:"channels"`
+	VideoCodec  sql.NullString  `db:"video_codec"`
+	AudioCodec  sql.NullString  `db:"audio_codec"`
+	FrameRate   sql.NullFloat64 `db:"frame_rate"`
+	Tags        string          `db:"tags"` // JSON-encoded map[string][]string of raw container tags
+	FolderID    sql.NullInt64   `db:"folder_id"`
+	CreatedAt   time.Time       `db:"created_at"`
+	UpdatedAt   time.Time       `db:"updated_at"`
 }
 
-// MediaDirectory represents a subdirectory in the media library.
+// MediaFileSync pairs a prepared media file row with its derived tags and
+// whether it's a new row or an update to an existing one, as produced by the
+// parallel media scanner's workers for its batched writer to apply.
+type MediaFileSync struct {
+	File  *MediaFile
+	IsNew bool
+	Tags  map[string][]string
+}
+
+// Folder represents a directory in the media library, indexed so Browse can
+// resolve children by folder ID instead of re-parsing path strings.
+type Folder struct {
+	ID       int64         `db:"id"`
+	Path     string        `db:"path"`
+	ParentID sql.NullInt64 `db:"parent_id"`
+	Name     string        `db:"name"`
+}
+
+// MediaDirectory represents a subdirectory in the media library, as
+// rendered to a Browse response. ID is 0 for synthetic containers (e.g. the
+// "by-genre" tag browse roots) that don't have a backing folders row.
 type MediaDirectory struct {
-	Path string
-	Name string
+	ID   int64  `db:"id"`
+	Path string `db:"path"`
+	Name string `db:"name"`
 }
 
 // Manager defines the interface for database operations.
@@ -47,10 +78,25 @@ type Manager interface {
 	GetFileByPath(path string) (*MediaFile, error)
 	GetFilesInDirectory(dirPath string) ([]MediaFile, error)
 	RemoveMediaFile(path string) (bool, error)
+	RemoveMediaFilesUnder(prefix string) (int, error)
 	UpdateMediaFile(file *MediaFile) error
-	GetDirectoryListing(parentPath, mediaTypeFilter string) ([]MediaDirectory, []MediaFile, error)
+	UpdateMediaFilePath(oldPath, newPath string) error
+	BatchSyncMediaFiles(batch []MediaFileSync) error
+	GetDirectoryListing(folderID int64, mediaTypeFilter string, sortKeys []SortKey) ([]MediaDirectory, []MediaFile, error)
+	GetOrCreateFolder(path string) (int64, error)
+	GetFolderByPath(path string) (*Folder, error)
+	CountChildren(folderID int64) (int, error)
 	GetAllPaths() ([]string, error)
 	CleanupMissingFiles(existingPaths []string) (int, error)
+	SearchMediaFiles(criteria SearchCriteria, start, count int) ([]MediaFile, int, error)
+	UpsertPlaylist(playlist *Playlist, tracks []PlaylistTrack) (int64, error)
+	GetPlaylistByPath(path string) (*Playlist, error)
+	GetPlaylistTracks(playlistID int64) ([]PlaylistTrack, error)
+	ListPlaylists() ([]Playlist, error)
+	ListTranscodeProfiles() ([]TranscodeProfile, error)
+	ReplaceItemTags(itemID int64, itemType string, tags map[string][]string) error
+	ListTagValues(name string) ([]string, error)
+	GetFilesByTag(name, value string) ([]MediaFile, error)
 	Close() error
 	// ... other methods from Rust trait
 }
@@ -67,7 +113,7 @@ func NewSqliteDatabase(dbPath string) (*SqliteDatabase, error) {
 		return nil, fmt.Errorf("failed to create database directory: %w", err)
 	}
 
-	db, err := sqlx.Connect("sqlite3", dbPath+"?_journal=WAL")
+	db, err := sqlx.Connect("sqlite", dbPath+"?_pragma=journal_mode(WAL)")
 	if err != nil {
 		return nil, err
 	}
@@ -81,8 +127,21 @@ func (s *SqliteDatabase) Close() error {
 }
 
 // Initialize creates the database schema.
+//
+// The media_files_fts virtual table needs FTS5, which is why the database
+// driver is modernc.org/sqlite rather than mattn/go-sqlite3: FTS5 ships
+// compiled in by default there, so a plain "go build"/"go test" (no driver-
+// specific build tags) gets a working full-text index.
 func (s *SqliteDatabase) Initialize() error {
 	schema := `
+	CREATE TABLE IF NOT EXISTS folders (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		path TEXT UNIQUE NOT NULL,
+		parent_id INTEGER REFERENCES folders(id) ON DELETE CASCADE,
+		name TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_folders_parent_id ON folders(parent_id);
+
 	CREATE TABLE IF NOT EXISTS media_files (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		path TEXT UNIQUE NOT NULL,
@@ -99,33 +158,203 @@ func (s *SqliteDatabase) Initialize() error {
 		track_number INTEGER,
 		year INTEGER,
 		album_artist TEXT,
+		disc INTEGER,
+		width INTEGER,
+		height INTEGER,
+		bitrate INTEGER,
+		channels INTEGER,
+		video_codec TEXT,
+		audio_codec TEXT,
+		frame_rate REAL,
+		tags TEXT NOT NULL DEFAULT '{}',
+		folder_id INTEGER REFERENCES folders(id) ON DELETE SET NULL,
 		created_at DATETIME NOT NULL,
 		updated_at DATETIME NOT NULL
 	);
 	CREATE INDEX IF NOT EXISTS idx_media_files_path ON media_files(path);
 	CREATE INDEX IF NOT EXISTS idx_media_files_parent_path ON media_files(parent_path);
+	CREATE INDEX IF NOT EXISTS idx_media_files_folder_id ON media_files(folder_id);
+
+	CREATE VIRTUAL TABLE IF NOT EXISTS media_files_fts USING fts5(
+		title, artist, album, album_artist, genre, filename,
+		content='media_files', content_rowid='id'
+	);
+
+	CREATE TRIGGER IF NOT EXISTS media_files_fts_ai AFTER INSERT ON media_files BEGIN
+		INSERT INTO media_files_fts(rowid, title, artist, album, album_artist, genre, filename)
+		VALUES (new.id, new.title, new.artist, new.album, new.album_artist, new.genre, new.filename);
+	END;
+	CREATE TRIGGER IF NOT EXISTS media_files_fts_ad AFTER DELETE ON media_files BEGIN
+		INSERT INTO media_files_fts(media_files_fts, rowid, title, artist, album, album_artist, genre, filename)
+		VALUES ('delete', old.id, old.title, old.artist, old.album, old.album_artist, old.genre, old.filename);
+	END;
+	CREATE TRIGGER IF NOT EXISTS media_files_fts_au AFTER UPDATE ON media_files BEGIN
+		INSERT INTO media_files_fts(media_files_fts, rowid, title, artist, album, album_artist, genre, filename)
+		VALUES ('delete', old.id, old.title, old.artist, old.album, old.album_artist, old.genre, old.filename);
+		INSERT INTO media_files_fts(rowid, title, artist, album, album_artist, genre, filename)
+		VALUES (new.id, new.title, new.artist, new.album, new.album_artist, new.genre, new.filename);
+	END;
+
+	CREATE TABLE IF NOT EXISTS playlists (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		path TEXT UNIQUE NOT NULL,
+		name TEXT NOT NULL,
+		modified DATETIME NOT NULL,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS playlist_tracks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		playlist_id INTEGER NOT NULL REFERENCES playlists(id) ON DELETE CASCADE,
+		position INTEGER NOT NULL,
+		media_file_id INTEGER REFERENCES media_files(id) ON DELETE SET NULL,
+		raw_entry TEXT NOT NULL,
+		duration_ms INTEGER,
+		UNIQUE(playlist_id, position)
+	);
+	CREATE INDEX IF NOT EXISTS idx_playlist_tracks_playlist_id ON playlist_tracks(playlist_id);
+
+	CREATE TABLE IF NOT EXISTS transcode_profiles (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		client_match TEXT NOT NULL,
+		source_mime_pattern TEXT NOT NULL,
+		target_mime TEXT NOT NULL,
+		ffmpeg_args_template TEXT NOT NULL,
+		bitrate_kbps INTEGER NOT NULL DEFAULT 0
+	);
+
+	CREATE TABLE IF NOT EXISTS tags (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		value TEXT NOT NULL,
+		UNIQUE(name, value)
+	);
+	CREATE INDEX IF NOT EXISTS idx_tags_name ON tags(name);
+
+	CREATE TABLE IF NOT EXISTS item_tags (
+		item_id INTEGER NOT NULL,
+		item_type TEXT NOT NULL,
+		tag_name TEXT NOT NULL,
+		tag_id TEXT NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+		UNIQUE(item_id, item_type, tag_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_item_tags_item ON item_tags(item_id, item_type);
+	CREATE INDEX IF NOT EXISTS idx_item_tags_tag ON item_tags(tag_id);
 	`
-	_, err := s.db.Exec(schema)
-	return err
+	if _, err := s.db.Exec(schema); err != nil {
+		return err
+	}
+
+	// media_files predates the video_codec/audio_codec/frame_rate columns;
+	// CREATE TABLE IF NOT EXISTS leaves an already-existing table as-is, so
+	// databases created before this change need them added explicitly.
+	for _, col := range []struct{ name, definition string }{
+		{"video_codec", "TEXT"},
+		{"audio_codec", "TEXT"},
+		{"frame_rate", "REAL"},
+	} {
+		if err := s.ensureColumn("media_files", col.name, col.definition); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-// StoreMediaFile adds a new media file to the database.
-func (s *SqliteDatabase) StoreMediaFile(file *MediaFile) (int64, error) {
-	query := `INSERT INTO media_files 
-	(path, parent_path, filename, size, modified, mime_type, duration, title, artist, album, genre, track_number, year, album_artist, created_at, updated_at)
-	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+// ensureColumn adds column to table if it doesn't already exist. SQLite has
+// no "ADD COLUMN IF NOT EXISTS", so this just attempts the ALTER TABLE and
+// swallows the "duplicate column name" error it raises when the column is
+// already there.
+func (s *SqliteDatabase) ensureColumn(table, column, definition string) error {
+	_, err := s.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
+	}
+	return nil
+}
+
+// sqlExecer is satisfied by both *sqlx.DB and *sqlx.Tx, letting
+// insertMediaFile/updateMediaFile run either directly against the database
+// or inside a caller-managed transaction (see BatchSyncMediaFiles).
+type sqlExecer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
 
+const insertMediaFileQuery = `INSERT INTO media_files
+	(path, parent_path, filename, size, modified, mime_type, duration, title, artist, album, genre, track_number, year, album_artist, disc, width, height, bitrate, channels, video_codec, audio_codec, frame_rate, tags, folder_id, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+const updateMediaFileQuery = `UPDATE media_files SET
+	parent_path = ?, filename = ?, size = ?, modified = ?, mime_type = ?,
+	duration = ?, title = ?, artist = ?, album = ?, genre = ?,
+	track_number = ?, year = ?, album_artist = ?, disc = ?, width = ?, height = ?,
+	bitrate = ?, channels = ?, video_codec = ?, audio_codec = ?, frame_rate = ?, tags = ?, folder_id = ?, updated_at = ?
+	WHERE id = ?`
+
+func insertMediaFile(exec sqlExecer, file *MediaFile) (int64, error) {
 	now := time.Now()
 	file.CreatedAt = now
 	file.UpdatedAt = now
+	if file.Tags == "" {
+		file.Tags = "{}"
+	}
 
-	res, err := s.db.Exec(query, file.Path, filepath.Dir(file.Path), file.Filename, file.Size, file.Modified, file.MimeType, file.Duration, file.Title, file.Artist, file.Album, file.Genre, file.TrackNumber, file.Year, file.AlbumArtist, file.CreatedAt, file.UpdatedAt)
+	res, err := exec.Exec(insertMediaFileQuery, file.Path, filepath.Dir(file.Path), file.Filename, file.Size, file.Modified, file.MimeType, file.Duration, file.Title, file.Artist, file.Album, file.Genre, file.TrackNumber, file.Year, file.AlbumArtist, file.Disc, file.Width, file.Height, file.Bitrate, file.Channels, file.VideoCodec, file.AudioCodec, file.FrameRate, file.Tags, file.FolderID, file.CreatedAt, file.UpdatedAt)
 	if err != nil {
 		return 0, err
 	}
 	return res.LastInsertId()
 }
 
+func updateMediaFile(exec sqlExecer, file *MediaFile) error {
+	file.UpdatedAt = time.Now()
+	if file.Tags == "" {
+		file.Tags = "{}"
+	}
+	_, err := exec.Exec(updateMediaFileQuery,
+		filepath.Dir(file.Path), file.Filename, file.Size, file.Modified, file.MimeType,
+		file.Duration, file.Title, file.Artist, file.Album, file.Genre,
+		file.TrackNumber, file.Year, file.AlbumArtist, file.Disc, file.Width, file.Height,
+		file.Bitrate, file.Channels, file.VideoCodec, file.AudioCodec, file.FrameRate, file.Tags, file.FolderID, file.UpdatedAt,
+		file.ID)
+	return err
+}
+
+// StoreMediaFile adds a new media file to the database.
+func (s *SqliteDatabase) StoreMediaFile(file *MediaFile) (int64, error) {
+	return insertMediaFile(s.db, file)
+}
+
+// BatchSyncMediaFiles inserts or updates a batch of prepared media files in
+// a single transaction, so the parallel scanner's writer goroutine can
+// amortize commit overhead across ~500 rows instead of one per file. On
+// success, each new item's File.ID is set to its assigned row ID.
+func (s *SqliteDatabase) BatchSyncMediaFiles(batch []MediaFileSync) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin batch sync transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for i := range batch {
+		item := &batch[i]
+		if item.IsNew {
+			id, err := insertMediaFile(tx, item.File)
+			if err != nil {
+				return fmt.Errorf("failed to insert %s: %w", item.File.Path, err)
+			}
+			item.File.ID = id
+		} else if err := updateMediaFile(tx, item.File); err != nil {
+			return fmt.Errorf("failed to update %s: %w", item.File.Path, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit batch sync: %w", err)
+	}
+	return nil
+}
+
 // GetFileByID retrieves a media file by its ID.
 func (s *SqliteDatabase) GetFileByID(id int64) (*MediaFile, error) {
 	var file MediaFile
@@ -165,106 +394,119 @@ func (s *SqliteDatabase) RemoveMediaFile(path string) (bool, error) {
 
 // UpdateMediaFile updates an existing media file record.
 func (s *SqliteDatabase) UpdateMediaFile(file *MediaFile) error {
-	query := `UPDATE media_files SET 
-		parent_path = ?, filename = ?, size = ?, modified = ?, mime_type = ?, 
-		duration = ?, title = ?, artist = ?, album = ?, genre = ?, 
-		track_number = ?, year = ?, album_artist = ?, updated_at = ?
-		WHERE id = ?`
-	file.UpdatedAt = time.Now()
-	_, err := s.db.Exec(query,
-		filepath.Dir(file.Path), file.Filename, file.Size, file.Modified, file.MimeType,
-		file.Duration, file.Title, file.Artist, file.Album, file.Genre,
-		file.TrackNumber, file.Year, file.AlbumArtist, file.UpdatedAt,
-		file.ID)
-	return err
+	return updateMediaFile(s.db, file)
 }
 
-// GetDirectoryListing retrieves subdirectories and files for a given path.
-func (s *SqliteDatabase) GetDirectoryListing(parentPath, mediaTypeFilter string) ([]MediaDirectory, []MediaFile, error) {
-	var files []MediaFile
-	filesQuery := "SELECT * FROM media_files WHERE parent_path = ? AND mime_type LIKE ? ORDER BY filename"
-	err := s.db.Select(&files, filesQuery, parentPath, mediaTypeFilter+"%")
+// RemoveMediaFilesUnder deletes every media file whose path is prefix or
+// lies under it (prefix/...), as used when a watched directory is removed
+// or renamed away. It returns the number of rows removed.
+func (s *SqliteDatabase) RemoveMediaFilesUnder(prefix string) (int, error) {
+	clean := filepath.Clean(prefix)
+	// Paths under clean sort byte-wise between clean+sep and clean+(sep+1);
+	// a range avoids LIKE's case folding and its "_"/"%" wildcards.
+	res, err := s.db.Exec("DELETE FROM media_files WHERE path = ? OR (path >= ? AND path < ?)",
+		clean, clean+string(filepath.Separator), clean+string(filepath.Separator+1))
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to get files: %w", err)
+		return 0, err
 	}
+	rows, err := res.RowsAffected()
+	return int(rows), err
+}
 
-	var subdirs []MediaDirectory
-	var subdirsQuery string
-	var queryArgs []interface{}
-
-	// Normalize parentPath for consistent SQL LIKE patterns.
-	// Treat "" and "/" as the canonical root.
-	normalizedParentPath := parentPath
-	if normalizedParentPath == string(filepath.Separator) {
-		normalizedParentPath = ""
+// UpdateMediaFilePath moves a media file's row from oldPath to newPath in
+// place, preserving its ID (and so any DIDL-Lite URLs already handed out to
+// renderers) instead of the caller deleting and re-inserting it. Used when
+// the watcher correlates a Rename+Create pair into a move.
+func (s *SqliteDatabase) UpdateMediaFilePath(oldPath, newPath string) error {
+	folderID, err := s.GetOrCreateFolder(filepath.Dir(newPath))
+	if err != nil {
+		return fmt.Errorf("failed to resolve folder for %q: %w", newPath, err)
+	}
+	_, err = s.db.Exec(
+		`UPDATE media_files SET path = ?, parent_path = ?, filename = ?, folder_id = ?, updated_at = ? WHERE path = ?`,
+		newPath, filepath.Dir(newPath), filepath.Base(newPath), folderID, time.Now(), oldPath)
+	return err
+}
+
+// GetOrCreateFolder returns the id of the folders row for path, creating it
+// (and any missing ancestors up to the filesystem root) if needed. Repeated
+// calls for the same path are idempotent and return the same id.
+func (s *SqliteDatabase) GetOrCreateFolder(path string) (int64, error) {
+	clean := filepath.Clean(path)
+
+	var id int64
+	err := s.db.Get(&id, "SELECT id FROM folders WHERE path = ?", clean)
+	if err == nil {
+		return id, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, fmt.Errorf("failed to look up folder %q: %w", clean, err)
 	}
 
-	if normalizedParentPath == "" {
-		// For the root, we want parent_paths that are top-level directories.
-		// These are paths that do not contain the path separator.
-		// e.g., "Music", "Videos", not "Music/Albums"
-		subdirsQuery = `
-			SELECT DISTINCT parent_path AS immediate_subdir_name
-			FROM media_files
-			WHERE parent_path != '' AND parent_path IS NOT NULL
-			  AND INSTR(parent_path, ?) = 0 -- No separator in the path itself
-			ORDER BY immediate_subdir_name;
-		`
-		queryArgs = []interface{}{string(filepath.Separator)}
-	} else {
-		// For a non-root path, we want the immediate subdirectories.
-		// e.g., for parentPath="/music", we want "album1" from "/music/album1" or "/music/album1/song.mp3"
-		// The parent_path in the DB for a file in "/music/album1" is "/music/album1".
-		// We need to extract the component immediately following `normalizedParentPath + separator`.
-		prefixWithSeparator := normalizedParentPath + string(filepath.Separator)
-		subdirsQuery = `
-			SELECT DISTINCT
-				CASE
-					WHEN INSTR(SUBSTR(parent_path, LENGTH(?) + 1), ?) > 0 THEN
-						SUBSTR(parent_path, LENGTH(?) + 1, INSTR(SUBSTR(parent_path, LENGTH(?) + 1), ?) - 1)
-					ELSE
-						SUBSTR(parent_path, LENGTH(?) + 1)
-				END AS immediate_subdir_name
-			FROM media_files
-			WHERE parent_path LIKE ? || '%' AND parent_path != ?
-			ORDER BY immediate_subdir_name;
-		`
-		// Corrected queryArgs: The original code had 7 arguments for 8 placeholders.
-		queryArgs = []interface{}{
-			prefixWithSeparator,        // 1st '?' in LENGTH(?) + 1 (first SUBSTR)
-			string(filepath.Separator), // 1st '?' in INSTR(..., ?)
-			prefixWithSeparator,        // 2nd '?' in LENGTH(?) + 1 (THEN clause, first SUBSTR)
-			prefixWithSeparator,        // 3rd '?' in LENGTH(?) + 1 (THEN clause, second SUBSTR)
-			string(filepath.Separator), // 2nd '?' in INSTR(..., ?) (THEN clause)
-			prefixWithSeparator,        // 4th '?' in LENGTH(?) + 1 (ELSE clause, SUBSTR)
-			prefixWithSeparator,        // 1st '?' in LIKE ? || '%'
-			normalizedParentPath,       // 1st '?' in parent_path != ?
+	var parentID sql.NullInt64
+	if parent := filepath.Dir(clean); parent != clean {
+		parentFolderID, err := s.GetOrCreateFolder(parent)
+		if err != nil {
+			return 0, err
 		}
+		parentID = sql.NullInt64{Int64: parentFolderID, Valid: true}
 	}
 
-	rows, err := s.db.Query(subdirsQuery, queryArgs...)
+	res, err := s.db.Exec(
+		`INSERT INTO folders (path, parent_id, name) VALUES (?, ?, ?) ON CONFLICT(path) DO NOTHING`,
+		clean, parentID, filepath.Base(clean))
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to get subdirectories: %w", err)
+		return 0, fmt.Errorf("failed to create folder %q: %w", clean, err)
 	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var name string
-		if err := rows.Scan(&name); err != nil {
-			fmt.Fprintf(os.Stderr, "Error scanning subdirectory name: %v\n", err)
-			continue
-		}
-		if name == "" {
-			continue
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		// Lost a race with a concurrent insert of the same folder.
+		if err := s.db.Get(&id, "SELECT id FROM folders WHERE path = ?", clean); err != nil {
+			return 0, fmt.Errorf("failed to look up folder %q after insert race: %w", clean, err)
 		}
-		subdirs = append(subdirs, MediaDirectory{
-			Name: name,
-			Path: filepath.Join(parentPath, name), // Use original parentPath for joining
-		})
+		return id, nil
+	}
+	return res.LastInsertId()
+}
+
+// GetFolderByPath looks up a folder by its filesystem path, returning nil if
+// it hasn't been created yet (e.g. before the first scan).
+func (s *SqliteDatabase) GetFolderByPath(path string) (*Folder, error) {
+	var folder Folder
+	err := s.db.Get(&folder, "SELECT * FROM folders WHERE path = ?", filepath.Clean(path))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &folder, err
+}
+
+// CountChildren returns the number of direct children (subfolders plus
+// media files) of a folder, for the DIDL-Lite childCount attribute.
+func (s *SqliteDatabase) CountChildren(folderID int64) (int, error) {
+	var count int
+	err := s.db.Get(&count, `
+		SELECT
+			(SELECT COUNT(*) FROM folders WHERE parent_id = ?) +
+			(SELECT COUNT(*) FROM media_files WHERE folder_id = ?)`,
+		folderID, folderID)
+	return count, err
+}
+
+// GetDirectoryListing retrieves the subfolders and media files directly
+// inside a folder, identified by its folders.id rather than a path string.
+// Files are ordered per sortKeys (the renderer's parsed SortCriteria), or by
+// filename if sortKeys is empty, so paging via StartingIndex/RequestedCount
+// is stable across repeated Browse calls either way.
+func (s *SqliteDatabase) GetDirectoryListing(folderID int64, mediaTypeFilter string, sortKeys []SortKey) ([]MediaDirectory, []MediaFile, error) {
+	var subdirs []MediaDirectory
+	subdirsQuery := "SELECT id, path, name FROM folders WHERE parent_id = ? ORDER BY name"
+	if err := s.db.Select(&subdirs, subdirsQuery, folderID); err != nil {
+		return nil, nil, fmt.Errorf("failed to get subdirectories: %w", err)
 	}
 
-	if err = rows.Err(); err != nil {
-		return nil, nil, fmt.Errorf("error iterating subdirectory rows: %w", err)
+	var files []MediaFile
+	filesQuery := fmt.Sprintf("SELECT * FROM media_files WHERE folder_id = ? AND mime_type LIKE ? ORDER BY %s", orderByClause(sortKeys))
+	if err := s.db.Select(&files, filesQuery, folderID, mediaTypeFilter+"%"); err != nil {
+		return nil, nil, fmt.Errorf("failed to get files: %w", err)
 	}
 
 	return subdirs, files, nil
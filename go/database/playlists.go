@@ -0,0 +1,107 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Playlist represents an M3U/PLS/CUE playlist file discovered on disk.
+type Playlist struct {
+	ID        int64     `db:"id"`
+	Path      string    `db:"path"`
+	Name      string    `db:"name"`
+	Modified  time.Time `db:"modified"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+// PlaylistTrack is one entry of a Playlist, resolved against media_files
+// where possible. MediaFileID is invalid when the entry could not be
+// matched to a known file.
+type PlaylistTrack struct {
+	ID          int64         `db:"id"`
+	PlaylistID  int64         `db:"playlist_id"`
+	Position    int           `db:"position"`
+	MediaFileID sql.NullInt64 `db:"media_file_id"`
+	RawEntry    string        `db:"raw_entry"`
+	DurationMs  sql.NullInt64 `db:"duration_ms"`
+}
+
+// UpsertPlaylist inserts or updates a playlist by path and replaces its
+// tracks wholesale, so a re-scan always reflects the current file contents.
+func (s *SqliteDatabase) UpsertPlaylist(playlist *Playlist, tracks []PlaylistTrack) (int64, error) {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin playlist upsert transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+
+	var id int64
+	err = tx.Get(&id, "SELECT id FROM playlists WHERE path = ?", playlist.Path)
+	switch {
+	case err == sql.ErrNoRows:
+		res, err := tx.Exec(
+			`INSERT INTO playlists (path, name, modified, created_at, updated_at) VALUES (?, ?, ?, ?, ?)`,
+			playlist.Path, playlist.Name, playlist.Modified, now, now)
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert playlist: %w", err)
+		}
+		id, err = res.LastInsertId()
+		if err != nil {
+			return 0, err
+		}
+	case err != nil:
+		return 0, fmt.Errorf("failed to look up existing playlist: %w", err)
+	default:
+		if _, err := tx.Exec(
+			`UPDATE playlists SET name = ?, modified = ?, updated_at = ? WHERE id = ?`,
+			playlist.Name, playlist.Modified, now, id); err != nil {
+			return 0, fmt.Errorf("failed to update playlist: %w", err)
+		}
+		if _, err := tx.Exec(`DELETE FROM playlist_tracks WHERE playlist_id = ?`, id); err != nil {
+			return 0, fmt.Errorf("failed to clear existing playlist tracks: %w", err)
+		}
+	}
+	playlist.ID = id
+
+	for _, track := range tracks {
+		if _, err := tx.Exec(
+			`INSERT INTO playlist_tracks (playlist_id, position, media_file_id, raw_entry, duration_ms) VALUES (?, ?, ?, ?, ?)`,
+			id, track.Position, track.MediaFileID, track.RawEntry, track.DurationMs); err != nil {
+			return 0, fmt.Errorf("failed to insert playlist track: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit playlist upsert: %w", err)
+	}
+
+	return id, nil
+}
+
+// GetPlaylistByPath retrieves a playlist by its source file path.
+func (s *SqliteDatabase) GetPlaylistByPath(path string) (*Playlist, error) {
+	var p Playlist
+	err := s.db.Get(&p, "SELECT * FROM playlists WHERE path = ?", path)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &p, err
+}
+
+// GetPlaylistTracks returns the tracks of a playlist in their original order.
+func (s *SqliteDatabase) GetPlaylistTracks(playlistID int64) ([]PlaylistTrack, error) {
+	var tracks []PlaylistTrack
+	err := s.db.Select(&tracks, "SELECT * FROM playlist_tracks WHERE playlist_id = ? ORDER BY position", playlistID)
+	return tracks, err
+}
+
+// ListPlaylists returns all known playlists, alphabetically by name.
+func (s *SqliteDatabase) ListPlaylists() ([]Playlist, error) {
+	var playlists []Playlist
+	err := s.db.Select(&playlists, "SELECT * FROM playlists ORDER BY name")
+	return playlists, err
+}
@@ -0,0 +1,238 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SearchOperator identifies the comparison used by a SearchPredicate.
+type SearchOperator string
+
+const (
+	SearchOpContains       SearchOperator = "contains"
+	SearchOpDoesNotContain SearchOperator = "doesNotContain"
+	SearchOpEquals         SearchOperator = "="
+	SearchOpNotEquals      SearchOperator = "!="
+	SearchOpDerivedFrom    SearchOperator = "derivedfrom"
+	SearchOpLess           SearchOperator = "<"
+	SearchOpLessEq         SearchOperator = "<="
+	SearchOpGreater        SearchOperator = ">"
+	SearchOpGreaterEq      SearchOperator = ">="
+	SearchOpExists         SearchOperator = "exists"
+)
+
+// ErrInvalidSearchCriteria wraps every error caused by a malformed or
+// unsupported SearchCriteria expression (unknown property, unsupported
+// operator, unparsable date, ...), so the web layer can tell a bad request
+// (UPnP error 708 InvalidArgs) apart from a genuine database failure.
+var ErrInvalidSearchCriteria = errors.New("invalid search criteria")
+
+// SearchPredicate is a single leaf comparison in a SearchCriteria expression,
+// e.g. `dc:title contains "love"`.
+type SearchPredicate struct {
+	Property string
+	Operator SearchOperator
+	Value    string
+}
+
+// SearchCriteria is a parsed UPnP ContentDirectory SearchCriteria expression.
+// It is either a match-all ("*"), a single predicate, or a boolean combination
+// of two sub-criteria joined by "and"/"or".
+type SearchCriteria struct {
+	MatchAll   bool
+	Predicate  *SearchPredicate
+	Combinator string // "and" or "or"; set when Left/Right are populated
+	Left       *SearchCriteria
+	Right      *SearchCriteria
+}
+
+// searchableColumns maps UPnP properties to the media_files_fts column used
+// for "contains"/"doesNotContain" matching.
+var searchableColumns = map[string]string{
+	"dc:title":    "title",
+	"upnp:artist": "artist",
+	"upnp:album":  "album",
+}
+
+// timestampColumns maps UPnP date properties to the media_files column
+// storing the corresponding time.Time, for equality/range comparisons.
+var timestampColumns = map[string]string{
+	"dc:date":          "modified",
+	"upnp:lastUpdated": "updated_at",
+}
+
+// SearchableProperties lists every UPnP property SearchMediaFiles accepts,
+// advertised verbatim by the ContentDirectory GetSearchCapabilities action.
+var SearchableProperties = []string{"upnp:class", "dc:title", "upnp:artist", "upnp:album", "dc:date", "upnp:lastUpdated"}
+
+// SearchMediaFiles resolves a parsed SearchCriteria against media_files,
+// using the media_files_fts full-text index for the text predicates.
+func (s *SqliteDatabase) SearchMediaFiles(criteria SearchCriteria, start, count int) ([]MediaFile, int, error) {
+	where, args, err := buildSearchWhere(criteria)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM media_files WHERE %s", where)
+	if err := s.db.Get(&total, countQuery, args...); err != nil {
+		return nil, 0, fmt.Errorf("failed to count search results: %w", err)
+	}
+
+	query := fmt.Sprintf("SELECT * FROM media_files WHERE %s ORDER BY filename LIMIT ? OFFSET ?", where)
+	queryArgs := append(append([]interface{}{}, args...), count, start)
+
+	var files []MediaFile
+	if err := s.db.Select(&files, query, queryArgs...); err != nil {
+		return nil, 0, fmt.Errorf("failed to run search query: %w", err)
+	}
+
+	return files, total, nil
+}
+
+func buildSearchWhere(c SearchCriteria) (string, []interface{}, error) {
+	if c.MatchAll {
+		return "1=1", nil, nil
+	}
+	if c.Predicate != nil {
+		return buildPredicateWhere(*c.Predicate)
+	}
+	if c.Left == nil || c.Right == nil {
+		return "", nil, fmt.Errorf("%w: missing operand for %q", ErrInvalidSearchCriteria, c.Combinator)
+	}
+
+	leftWhere, leftArgs, err := buildSearchWhere(*c.Left)
+	if err != nil {
+		return "", nil, err
+	}
+	rightWhere, rightArgs, err := buildSearchWhere(*c.Right)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var joiner string
+	switch c.Combinator {
+	case "and":
+		joiner = "AND"
+	case "or":
+		joiner = "OR"
+	default:
+		return "", nil, fmt.Errorf("%w: unsupported search combinator %q", ErrInvalidSearchCriteria, c.Combinator)
+	}
+
+	return fmt.Sprintf("(%s %s %s)", leftWhere, joiner, rightWhere), append(leftArgs, rightArgs...), nil
+}
+
+func buildPredicateWhere(p SearchPredicate) (string, []interface{}, error) {
+	if p.Property == "upnp:class" {
+		if p.Operator != SearchOpDerivedFrom {
+			return "", nil, fmt.Errorf("%w: operator %q is not supported for upnp:class", ErrInvalidSearchCriteria, p.Operator)
+		}
+		prefix, ok := upnpClassToMimePrefix(p.Value)
+		if !ok {
+			return "", nil, fmt.Errorf("%w: unsupported upnp:class value %q", ErrInvalidSearchCriteria, p.Value)
+		}
+		return "mime_type LIKE ?", []interface{}{prefix + "%"}, nil
+	}
+
+	if column, ok := timestampColumns[p.Property]; ok {
+		return buildTimestampWhere(column, p)
+	}
+
+	column, ok := searchableColumns[p.Property]
+	if !ok {
+		return "", nil, fmt.Errorf("%w: unsupported search property %q", ErrInvalidSearchCriteria, p.Property)
+	}
+
+	switch p.Operator {
+	case SearchOpContains:
+		return "id IN (SELECT rowid FROM media_files_fts WHERE media_files_fts MATCH ?)",
+			[]interface{}{fmt.Sprintf("%s:%s", column, ftsQuote(p.Value))}, nil
+	case SearchOpDoesNotContain:
+		return "id NOT IN (SELECT rowid FROM media_files_fts WHERE media_files_fts MATCH ?)",
+			[]interface{}{fmt.Sprintf("%s:%s", column, ftsQuote(p.Value))}, nil
+	case SearchOpEquals:
+		return fmt.Sprintf("%s = ?", column), []interface{}{p.Value}, nil
+	case SearchOpNotEquals:
+		return fmt.Sprintf("(%s IS NULL OR %s != ?)", column, column), []interface{}{p.Value}, nil
+	case SearchOpExists:
+		return existsWhere(column, p.Value)
+	default:
+		return "", nil, fmt.Errorf("%w: operator %q is not supported for %q", ErrInvalidSearchCriteria, p.Operator, p.Property)
+	}
+}
+
+// buildTimestampWhere lowers a dc:date/upnp:lastUpdated predicate to a
+// comparison against column, parsing Value with parseSearchDate.
+func buildTimestampWhere(column string, p SearchPredicate) (string, []interface{}, error) {
+	if p.Operator == SearchOpExists {
+		return existsWhere(column, p.Value)
+	}
+
+	t, err := parseSearchDate(p.Value)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %v", ErrInvalidSearchCriteria, err)
+	}
+
+	switch p.Operator {
+	case SearchOpEquals:
+		return fmt.Sprintf("%s = ?", column), []interface{}{t}, nil
+	case SearchOpNotEquals:
+		return fmt.Sprintf("(%s IS NULL OR %s != ?)", column, column), []interface{}{t}, nil
+	case SearchOpLess:
+		return fmt.Sprintf("%s < ?", column), []interface{}{t}, nil
+	case SearchOpLessEq:
+		return fmt.Sprintf("%s <= ?", column), []interface{}{t}, nil
+	case SearchOpGreater:
+		return fmt.Sprintf("%s > ?", column), []interface{}{t}, nil
+	case SearchOpGreaterEq:
+		return fmt.Sprintf("%s >= ?", column), []interface{}{t}, nil
+	default:
+		return "", nil, fmt.Errorf("%w: operator %q is not supported for %q", ErrInvalidSearchCriteria, p.Operator, p.Property)
+	}
+}
+
+// parseSearchDate parses a UPnP SearchCriteria date value, accepting either
+// a full RFC3339/"2006-01-02T15:04:05" timestamp or a bare "2006-01-02" date.
+func parseSearchDate(value string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized date %q", value)
+}
+
+// existsWhere builds the WHERE fragment for the "exists" operator, which
+// UPnP clients use to filter on whether an optional property is present.
+func existsWhere(column, value string) (string, []interface{}, error) {
+	switch strings.ToLower(value) {
+	case "true":
+		return fmt.Sprintf("%s IS NOT NULL", column), nil, nil
+	case "false":
+		return fmt.Sprintf("%s IS NULL", column), nil, nil
+	default:
+		return "", nil, fmt.Errorf("%w: exists value must be \"true\" or \"false\", got %q", ErrInvalidSearchCriteria, value)
+	}
+}
+
+// ftsQuote wraps a value in double quotes so FTS5 treats spaces and
+// punctuation as a single phrase rather than separate terms.
+func ftsQuote(value string) string {
+	return `"` + strings.ReplaceAll(value, `"`, `""`) + `"`
+}
+
+func upnpClassToMimePrefix(class string) (string, bool) {
+	switch {
+	case strings.HasPrefix(class, "object.item.audioItem"):
+		return "audio/", true
+	case strings.HasPrefix(class, "object.item.videoItem"):
+		return "video/", true
+	case strings.HasPrefix(class, "object.item.imageItem"):
+		return "image/", true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,77 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SortableProperties lists the dc:/upnp:/res@ properties Browse can sort
+// by, advertised via the ContentDirectory GetSortCapabilities action.
+var SortableProperties = []string{"dc:title", "dc:date", "upnp:class", "res@size"}
+
+// sortColumns maps each sortable DIDL-Lite property to its backing
+// media_files column.
+var sortColumns = map[string]string{
+	"dc:title":   "filename",
+	"dc:date":    "year",
+	"upnp:class": "mime_type",
+	"res@size":   "size",
+}
+
+// ErrInvalidSortCriteria wraps every error caused by a malformed or
+// unsupported SortCriteria expression, so the web layer can tell a bad
+// request (UPnP error 708 InvalidArgs) apart from a genuine database
+// failure, mirroring ErrInvalidSearchCriteria.
+var ErrInvalidSortCriteria = errors.New("invalid sort criteria")
+
+// SortKey is one parsed SortCriteria token: a DIDL-Lite property plus its
+// sort direction.
+type SortKey struct {
+	Property   string
+	Descending bool
+}
+
+// ParseSortCriteria parses a UPnP SortCriteria string: a comma-separated
+// list of "+property" (ascending) or "-property" (descending) tokens, e.g.
+// "+dc:title,-dc:date". An empty string yields no sort keys, meaning the
+// caller's default order.
+func ParseSortCriteria(raw string) ([]SortKey, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+
+	var keys []SortKey
+	for _, token := range strings.Split(raw, ",") {
+		token = strings.TrimSpace(token)
+		if len(token) < 2 || (token[0] != '+' && token[0] != '-') {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidSortCriteria, token)
+		}
+		property := token[1:]
+		if _, ok := sortColumns[property]; !ok {
+			return nil, fmt.Errorf("%w: unsupported property %q", ErrInvalidSortCriteria, property)
+		}
+		keys = append(keys, SortKey{Property: property, Descending: token[0] == '-'})
+	}
+	return keys, nil
+}
+
+// orderByClause renders keys as a SQL ORDER BY clause (without the ORDER BY
+// keywords), falling back to ordering by filename when keys is empty so
+// paging stays stable across calls regardless of whether the renderer asked
+// for a specific order.
+func orderByClause(keys []SortKey) string {
+	if len(keys) == 0 {
+		return "filename"
+	}
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		col := sortColumns[k.Property]
+		if k.Descending {
+			col += " DESC"
+		}
+		parts = append(parts, col)
+	}
+	return strings.Join(parts, ", ")
+}
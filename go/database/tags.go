@@ -0,0 +1,71 @@
+package database
+
+import "fmt"
+
+// itemTagID returns the deterministic primary key used for a (name, value)
+// pair in the tags table, so repeated syncs of the same tag reuse one row.
+func itemTagID(name, value string) string {
+	return name + ":" + value
+}
+
+// ReplaceItemTags replaces all tags associated with an item (identified by
+// itemID and itemType, e.g. "media_file") with the given multimap. Each
+// distinct (name, value) pair is upserted into tags and linked via
+// item_tags, so values shared by many items are stored once.
+func (s *SqliteDatabase) ReplaceItemTags(itemID int64, itemType string, tags map[string][]string) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin tag sync transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM item_tags WHERE item_id = ? AND item_type = ?`, itemID, itemType); err != nil {
+		return fmt.Errorf("failed to clear existing item tags: %w", err)
+	}
+
+	for name, values := range tags {
+		for _, value := range values {
+			if value == "" {
+				continue
+			}
+			tagID := itemTagID(name, value)
+			if _, err := tx.Exec(
+				`INSERT INTO tags (id, name, value) VALUES (?, ?, ?) ON CONFLICT(name, value) DO NOTHING`,
+				tagID, name, value); err != nil {
+				return fmt.Errorf("failed to upsert tag: %w", err)
+			}
+			if _, err := tx.Exec(
+				`INSERT INTO item_tags (item_id, item_type, tag_name, tag_id) VALUES (?, ?, ?, ?) ON CONFLICT(item_id, item_type, tag_id) DO NOTHING`,
+				itemID, itemType, name, tagID); err != nil {
+				return fmt.Errorf("failed to link item tag: %w", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit tag sync: %w", err)
+	}
+	return nil
+}
+
+// ListTagValues returns all distinct values stored for a tag name, e.g. every
+// known genre, sorted alphabetically.
+func (s *SqliteDatabase) ListTagValues(name string) ([]string, error) {
+	var values []string
+	err := s.db.Select(&values, "SELECT value FROM tags WHERE name = ? ORDER BY value", name)
+	return values, err
+}
+
+// GetFilesByTag returns every media file tagged with the given (name, value)
+// pair, e.g. all files with genre "Jazz".
+func (s *SqliteDatabase) GetFilesByTag(name, value string) ([]MediaFile, error) {
+	var files []MediaFile
+	query := `
+		SELECT mf.* FROM media_files mf
+		JOIN item_tags it ON it.item_id = mf.id AND it.item_type = 'media_file'
+		JOIN tags t ON t.id = it.tag_id
+		WHERE t.name = ? AND t.value = ?
+		ORDER BY mf.filename`
+	err := s.db.Select(&files, query, name, value)
+	return files, err
+}
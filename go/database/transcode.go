@@ -0,0 +1,21 @@
+package database
+
+// TranscodeProfile describes a single on-the-fly transcoding target: which
+// clients and source formats it applies to, and the ffmpeg invocation used
+// to produce the target format.
+type TranscodeProfile struct {
+	ID                 int64  `db:"id"`
+	ClientMatch        string `db:"client_match"`
+	SourceMimePattern  string `db:"source_mime_pattern"`
+	TargetMime         string `db:"target_mime"`
+	FFmpegArgsTemplate string `db:"ffmpeg_args_template"`
+	BitrateKbps        int    `db:"bitrate_kbps"`
+}
+
+// ListTranscodeProfiles returns all configured transcode profiles, in the
+// order they should be tried against an incoming request.
+func (s *SqliteDatabase) ListTranscodeProfiles() ([]TranscodeProfile, error) {
+	var profiles []TranscodeProfile
+	err := s.db.Select(&profiles, "SELECT * FROM transcode_profiles ORDER BY id")
+	return profiles, err
+}
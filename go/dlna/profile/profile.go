@@ -0,0 +1,122 @@
+// Package profile maps a database.MediaFile's container, codec, and
+// resolution onto a DLNA.ORG_PN profile name and builds the full
+// protocolInfo string DLNA renderers (Samsung, LG, Sony smart-TVs in
+// particular) expect in a <res> element's protocolInfo attribute before
+// they'll trust a stream enough to play it without probing it first.
+package profile
+
+import (
+	"fmt"
+	"strings"
+
+	"vuio-go/database"
+)
+
+// streamableFlags is DLNA.ORG_FLAGS for content that supports byte-range
+// seeking and can be streamed directly, the common case for files served
+// whole by serveMediaHandler.
+const streamableFlags = "01700000000000000000000000000000"
+
+// transcodePN maps a transcode target mime type to the DLNA.ORG_PN profile
+// its rendition conforms to. Target mime types with no entry just omit
+// DLNA.ORG_PN from their protocolInfo.
+var transcodePN = map[string]string{
+	"video/mp2t": "MPEG_TS_SD_EU_ISO",
+	"video/mp4":  "AVC_MP4_MP_SD_AAC_MULT5",
+	"audio/mpeg": "MP3",
+}
+
+// ProtocolInfo builds the protocolInfo string for file's original <res>,
+// detecting its DLNA.ORG_PN from the codec/resolution ffprobe recorded at
+// scan time.
+func ProtocolInfo(file database.MediaFile) string {
+	return protocolInfo(file.MimeType, detectPN(file))
+}
+
+// TranscodedProtocolInfo builds the protocolInfo string for an additional
+// <res> produced by a transcode profile, whose DLNA.ORG_PN can only be
+// inferred from its target mime type since the output hasn't been probed.
+func TranscodedProtocolInfo(targetMime string) string {
+	return protocolInfo(targetMime, transcodePN[targetMime])
+}
+
+func protocolInfo(mime, pn string) string {
+	if pn == "" {
+		return fmt.Sprintf("http-get:*:%s:DLNA.ORG_OP=01;DLNA.ORG_CI=0;DLNA.ORG_FLAGS=%s", mime, streamableFlags)
+	}
+	return fmt.Sprintf("http-get:*:%s:DLNA.ORG_PN=%s;DLNA.ORG_OP=01;DLNA.ORG_CI=0;DLNA.ORG_FLAGS=%s", mime, pn, streamableFlags)
+}
+
+// detectPN returns file's DLNA.ORG_PN profile name, or "" if its container,
+// codec, or resolution doesn't map to one of the handful of profiles
+// recognized here.
+func detectPN(file database.MediaFile) string {
+	switch {
+	case strings.HasPrefix(file.MimeType, "video/"):
+		return videoPN(file)
+	case strings.HasPrefix(file.MimeType, "audio/"):
+		return audioPN(file)
+	case strings.HasPrefix(file.MimeType, "image/"):
+		return imagePN(file)
+	default:
+		return ""
+	}
+}
+
+func videoPN(file database.MediaFile) string {
+	codec := strings.ToLower(file.VideoCodec.String)
+	height := int32(0)
+	if file.Height.Valid {
+		height = file.Height.Int32
+	}
+
+	switch {
+	case file.MimeType == "video/mp4" && (codec == "h264" || codec == "avc"):
+		if height >= 720 {
+			return "AVC_MP4_MP_HD_720p_AAC"
+		}
+		return "AVC_MP4_MP_SD_AAC_MULT5"
+	case file.MimeType == "video/mp2t":
+		return "MPEG_TS_SD_EU_ISO"
+	default:
+		return ""
+	}
+}
+
+func audioPN(file database.MediaFile) string {
+	codec := strings.ToLower(file.AudioCodec.String)
+	switch {
+	case file.MimeType == "audio/mpeg":
+		return "MP3"
+	case strings.HasPrefix(codec, "pcm_"):
+		return "LPCM"
+	default:
+		return ""
+	}
+}
+
+func imagePN(file database.MediaFile) string {
+	if file.MimeType != "image/jpeg" {
+		return ""
+	}
+	width, height := int32(0), int32(0)
+	if file.Width.Valid {
+		width = file.Width.Int32
+	}
+	if file.Height.Valid {
+		height = file.Height.Int32
+	}
+
+	switch {
+	case width == 0 || height == 0:
+		return ""
+	case width <= 160 && height <= 160:
+		return "JPEG_TN"
+	case width <= 640 && height <= 480:
+		return "JPEG_SM"
+	case width <= 1024 && height <= 768:
+		return "JPEG_MED"
+	default:
+		return "JPEG_LRG"
+	}
+}
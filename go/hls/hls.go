@@ -0,0 +1,291 @@
+// Package hls segments media files into on-demand HLS renditions for
+// playback in browsers and mobile clients that can't consume raw UPnP
+// streams directly.
+package hls
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"vuio-go/database"
+)
+
+// Rung is one rendition rung in the multi-bitrate ladder.
+type Rung struct {
+	Name        string
+	Height      int
+	BitrateKbps int
+}
+
+// ladder is the full rung set offered; a session only serves rungs at or
+// below the source's own height, since upscaling wastes bitrate without
+// improving quality.
+var ladder = []Rung{
+	{Name: "1080p", Height: 1080, BitrateKbps: 5000},
+	{Name: "720p", Height: 720, BitrateKbps: 2800},
+	{Name: "480p", Height: 480, BitrateKbps: 1400},
+}
+
+// rungVariant tracks one resolution rung's segmenting state within a
+// session, so concurrent requests for the same rung wait on (rather than
+// race to start) a single ffmpeg process.
+type rungVariant struct {
+	mu      sync.Mutex
+	started bool
+	ready   chan struct{}
+	err     error
+	cmd     *exec.Cmd
+}
+
+// Session is one in-progress HLS segmenting job for a single media file,
+// covering every rung in its ladder.
+type Session struct {
+	mediaID  int64
+	dir      string
+	rungs    []Rung
+	variants map[string]*rungVariant
+
+	mu       sync.Mutex
+	lastUsed time.Time
+}
+
+func (s *Session) touch() {
+	s.mu.Lock()
+	s.lastUsed = time.Now()
+	s.mu.Unlock()
+}
+
+func (s *Session) idleSince() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastUsed
+}
+
+// Rung looks up a ladder rung by name within the session.
+func (s *Session) Rung(name string) (Rung, bool) {
+	for _, r := range s.rungs {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return Rung{}, false
+}
+
+// MasterPlaylist renders the multi-bitrate master playlist, with one
+// variant stream per ladder rung pointing at that rung's own playlist.
+func (s *Session) MasterPlaylist() string {
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n")
+	for _, r := range s.rungs {
+		// Width is derived assuming 16:9 source video; the profile only
+		// needs to be a reasonable hint for ABR client selection.
+		width := r.Height * 16 / 9
+		fmt.Fprintf(&b, "#EXT-X-STREAM-INF:BANDWIDTH=%d,RESOLUTION=%dx%d\n", r.BitrateKbps*1000, width, r.Height)
+		fmt.Fprintf(&b, "%s/index.m3u8\n", r.Name)
+	}
+	return b.String()
+}
+
+// FilePath returns the on-disk path for a rung's playlist or segment file.
+func (s *Session) FilePath(rungName, fileName string) (string, error) {
+	if strings.ContainsAny(fileName, "/\\") {
+		return "", fmt.Errorf("invalid HLS file name: %s", fileName)
+	}
+	return filepath.Join(s.dir, rungName, fileName), nil
+}
+
+// EnsureRungStarted starts ffmpeg segmenting the given rung if it isn't
+// already running, serializing concurrent requests for the same rung so
+// only one ffmpeg process ever writes to its output directory.
+func (s *Session) EnsureRungStarted(ctx context.Context, sourcePath string, rung Rung) error {
+	variant, ok := s.variants[rung.Name]
+	if !ok {
+		return fmt.Errorf("unknown HLS rung: %s", rung.Name)
+	}
+
+	variant.mu.Lock()
+	if variant.started {
+		variant.mu.Unlock()
+		<-variant.ready
+		return variant.err
+	}
+	variant.started = true
+	variant.mu.Unlock()
+
+	rungDir := filepath.Join(s.dir, rung.Name)
+	if err := os.MkdirAll(rungDir, 0755); err != nil {
+		variant.err = fmt.Errorf("failed to create HLS rung directory: %w", err)
+		close(variant.ready)
+		return variant.err
+	}
+
+	playlistPath := filepath.Join(rungDir, "index.m3u8")
+	args := []string{
+		"-i", sourcePath,
+		"-vf", fmt.Sprintf("scale=-2:%d", rung.Height),
+		"-c:v", "libx264",
+		"-b:v", fmt.Sprintf("%dk", rung.BitrateKbps),
+		"-c:a", "aac",
+		"-f", "hls",
+		"-hls_time", "4",
+		"-hls_playlist_type", "vod",
+		"-hls_segment_filename", filepath.Join(rungDir, "segment_%d.ts"),
+		playlistPath,
+	}
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	variant.cmd = cmd
+	if err := cmd.Start(); err != nil {
+		variant.err = fmt.Errorf("failed to start HLS segmenter for rung %s: %w", rung.Name, err)
+		close(variant.ready)
+		return variant.err
+	}
+
+	go func() {
+		if err := cmd.Wait(); err != nil {
+			slog.Debug("HLS segmenter process exited", "rung", rung.Name, "error", err)
+		}
+	}()
+
+	// The playlist file appears once ffmpeg has written its first segment;
+	// poll for it so callers don't serve a 404 while encoding is starting.
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if _, err := os.Stat(playlistPath); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			variant.err = fmt.Errorf("timed out waiting for HLS playlist for rung %s", rung.Name)
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	close(variant.ready)
+	return variant.err
+}
+
+// Stop kills every rung's ffmpeg process and removes the session's segment
+// directory. Used on idle eviction.
+func (s *Session) Stop() {
+	for _, v := range s.variants {
+		v.mu.Lock()
+		if v.cmd != nil && v.cmd.Process != nil {
+			_ = v.cmd.Process.Kill()
+		}
+		v.mu.Unlock()
+	}
+	_ = os.RemoveAll(s.dir)
+}
+
+// Manager tracks active HLS sessions keyed by media ID, evicting ones idle
+// for longer than idleTimeout and killing their ffmpeg processes.
+type Manager struct {
+	baseDir     string
+	idleTimeout time.Duration
+
+	mu       sync.Mutex
+	sessions map[int64]*Session
+}
+
+// NewManager creates a Manager storing per-session segment directories
+// under baseDir, reaping sessions idle for longer than idleTimeout.
+func NewManager(baseDir string, idleTimeout time.Duration) *Manager {
+	m := &Manager{baseDir: baseDir, idleTimeout: idleTimeout, sessions: make(map[int64]*Session)}
+	go m.reapLoop()
+	return m
+}
+
+// GetOrCreateSession returns the active session for mf, starting a new one
+// (probing the source height to trim the rung ladder) if none exists yet.
+func (m *Manager) GetOrCreateSession(mf *database.MediaFile) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if sess, ok := m.sessions[mf.ID]; ok {
+		sess.touch()
+		return sess, nil
+	}
+
+	rungs := selectRungs(probeHeight(mf.Path))
+	dir := filepath.Join(m.baseDir, strconv.FormatInt(mf.ID, 10))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create HLS session directory: %w", err)
+	}
+
+	sess := &Session{
+		mediaID:  mf.ID,
+		dir:      dir,
+		rungs:    rungs,
+		variants: make(map[string]*rungVariant, len(rungs)),
+		lastUsed: time.Now(),
+	}
+	for _, r := range rungs {
+		sess.variants[r.Name] = &rungVariant{ready: make(chan struct{})}
+	}
+
+	m.sessions[mf.ID] = sess
+	return sess, nil
+}
+
+func (m *Manager) reapLoop() {
+	ticker := time.NewTicker(m.idleTimeout / 2)
+	defer ticker.Stop()
+	for range ticker.C {
+		m.reapIdle()
+	}
+}
+
+func (m *Manager) reapIdle() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, sess := range m.sessions {
+		if time.Since(sess.idleSince()) > m.idleTimeout {
+			slog.Info("Evicting idle HLS session", "mediaID", id)
+			sess.Stop()
+			delete(m.sessions, id)
+		}
+	}
+}
+
+// selectRungs trims the full ladder to rungs at or below sourceHeight. If
+// sourceHeight is unknown (0), the full ladder is offered.
+func selectRungs(sourceHeight int) []Rung {
+	if sourceHeight <= 0 {
+		return ladder
+	}
+	var rungs []Rung
+	for _, r := range ladder {
+		if r.Height <= sourceHeight {
+			rungs = append(rungs, r)
+		}
+	}
+	if len(rungs) == 0 {
+		// Source is smaller than our lowest rung; still offer one
+		// rendition rather than none.
+		rungs = append(rungs, ladder[len(ladder)-1])
+	}
+	return rungs
+}
+
+// probeHeight shells out to ffprobe for the source video's height, returning
+// 0 if it can't be determined.
+func probeHeight(path string) int {
+	out, err := exec.Command("ffprobe", "-v", "error", "-select_streams", "v:0",
+		"-show_entries", "stream=height", "-of", "csv=p=0", path).Output()
+	if err != nil {
+		return 0
+	}
+	height, err := strconv.Atoi(strings.TrimSpace(string(out)))
+	if err != nil {
+		return 0
+	}
+	return height
+}
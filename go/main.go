@@ -12,11 +12,15 @@ import (
 
 	"vuio-go/config"
 	"vuio-go/database"
+	"vuio-go/hls"
 	"vuio-go/logging"
 	"vuio-go/media"
 	"vuio-go/platform"
+	"vuio-go/playlist"
 	"vuio-go/ssdp"
 	"vuio-go/state"
+	"vuio-go/thumbnail"
+	"vuio-go/transcode"
 	"vuio-go/watcher"
 	"vuio-go/web"
 )
@@ -76,14 +80,58 @@ func main() {
 	// Create shared application state
 	appState := state.New(cfg, db, plat)
 
+	if cfg.Transcode.Enabled {
+		profiles, err := db.ListTranscodeProfiles()
+		if err != nil {
+			slog.Error("Failed to load transcode profiles", "error", err)
+		} else {
+			appState.TranscodeProfiles = profiles
+			slog.Info("Loaded transcode profiles", "count", len(profiles))
+		}
+
+		transcoder, err := transcode.NewCachingTranscoder(cfg.Transcode.CacheDir, cfg.Transcode.MaxCacheSizeMB*1024*1024)
+		if err != nil {
+			slog.Error("Failed to initialize transcode cache", "error", err)
+		} else {
+			appState.Transcoder = transcoder
+		}
+
+		appState.Capabilities = transcode.DetectCapabilities(cfg.Transcode.VaapiDevice)
+		slog.Info("Detected transcode capabilities",
+			"ffmpeg", appState.Capabilities.FFmpegAvailable,
+			"hwaccels", appState.Capabilities.HWAccels,
+			"vaapi_device", appState.Capabilities.VaapiDevice)
+	}
+
+	if cfg.Hls.Enabled {
+		appState.HLS = hls.NewManager(cfg.Hls.CacheDir, time.Duration(cfg.Hls.SessionIdleSeconds)*time.Second)
+	}
+
+	if cfg.Thumbnail.Enabled {
+		gen, err := thumbnail.NewGenerator(cfg.Thumbnail.CacheDir, cfg.Thumbnail.SizePx)
+		if err != nil {
+			slog.Error("Failed to initialize thumbnail generator", "error", err)
+		} else {
+			appState.Thumbnails = gen
+		}
+	}
+
 	// Perform initial media scan
 	if cfg.Media.ScanOnStartup {
 		slog.Info("Performing initial media scan...")
 		scanner := media.NewScanner(db)
-		if err := scanner.ScanAllDirectories(cfg); err != nil {
+		if err := scanner.ScanAllDirectories(ctx, cfg); err != nil {
 			slog.Error("Initial media scan failed", "error", err)
 			// Don't exit, server can still run
 		}
+
+		slog.Info("Scanning for playlists...")
+		playlistScanner := playlist.NewScanner(db)
+		for _, dir := range cfg.Media.Directories {
+			if err := playlistScanner.ScanDirectory(dir.Path); err != nil {
+				slog.Error("Failed to scan for playlists", "path", dir.Path, "error", err)
+			}
+		}
 	} else {
 		slog.Info("Skipping media scan on startup as configured")
 	}
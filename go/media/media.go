@@ -1,39 +1,94 @@
 package media
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"io/fs"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"vuio-go/config"
 	"vuio-go/database"
+	"vuio-go/metadata"
 )
 
+// batchSize is how many prepared rows the writer goroutine commits per
+// transaction, amortizing SQLite's per-commit fsync cost across a scan.
+const batchSize = 500
+
 // Scanner handles scanning media directories.
 type Scanner struct {
-	db database.Manager
+	db       database.Manager
+	workers  int
+	progress chan ProgressEvent
 }
 
-// NewScanner creates a new media scanner.
+// NewScanner creates a new media scanner. It walks directories with
+// runtime.NumCPU() worker goroutines consuming candidate paths in parallel;
+// use WithWorkers to override that.
 func NewScanner(db database.Manager) *Scanner {
-	return &Scanner{db: db}
+	return &Scanner{
+		db:       db,
+		workers:  runtime.NumCPU(),
+		progress: make(chan ProgressEvent, 100),
+	}
+}
+
+// WithWorkers overrides the scanner's worker pool size.
+func (s *Scanner) WithWorkers(n int) *Scanner {
+	if n > 0 {
+		s.workers = n
+	}
+	return s
 }
 
-// ScanAllDirectories scans all directories configured in AppConfig.
-func (s *Scanner) ScanAllDirectories(cfg *config.AppConfig) error {
+// Progress returns the channel on which scan progress events are emitted.
+// Sends are non-blocking, so a scan never stalls if nothing is receiving;
+// callers that want every event should keep the channel drained for the
+// scan's duration.
+func (s *Scanner) Progress() <-chan ProgressEvent {
+	return s.progress
+}
+
+// ScanAllDirectories scans all directories configured in AppConfig, plus any
+// configured media root not already covered by one of them. It stops
+// starting new directories once ctx is canceled.
+func (s *Scanner) ScanAllDirectories(ctx context.Context, cfg *config.AppConfig) error {
 	slog.Info("Starting media scan for all configured directories")
+	scanned := make(map[string]bool, len(cfg.Media.Directories))
 	for _, dir := range cfg.Media.Directories {
+		if ctx.Err() != nil {
+			break
+		}
+		scanned[dir.Path] = true
 		slog.Info("Scanning directory", "path", dir.Path)
-		if err := s.ScanDirectory(&dir); err != nil {
+		if err := s.ScanDirectory(ctx, &dir); err != nil {
 			slog.Error("Failed to scan directory", "path", dir.Path, "error", err)
 			// Continue to next directory
 		}
 	}
 
+	for _, root := range cfg.GetMediaRoots() {
+		if ctx.Err() != nil {
+			break
+		}
+		if scanned[root.Path] {
+			continue
+		}
+		slog.Info("Scanning media root", "name", root.Name, "path", root.Path)
+		rootDir := config.MonitoredDirectoryConfig{Path: root.Path, Recursive: true}
+		if err := s.ScanDirectory(ctx, &rootDir); err != nil {
+			slog.Error("Failed to scan media root", "name", root.Name, "path", root.Path, "error", err)
+		}
+	}
+
 	if cfg.Media.CleanupDeletedFiles {
 		slog.Info("Cleaning up deleted files from database...")
 		if err := s.cleanup(); err != nil {
@@ -45,53 +100,189 @@ func (s *Scanner) ScanAllDirectories(cfg *config.AppConfig) error {
 	return nil
 }
 
-// ScanDirectory scans a single directory configuration.
-func (s *Scanner) ScanDirectory(dirConfig *config.MonitoredDirectoryConfig) error {
-	return filepath.Walk(dirConfig.Path, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+// ScanDirectory walks dirConfig's tree with a filepath.WalkDir producer
+// feeding a pool of worker goroutines (see Scanner.workers), which run the
+// I/O-bound stat/probe/DB-read work of preparing each file concurrently. A
+// single writer goroutine commits their results in batches of batchSize
+// rows, and Progress() emits an event after every file is prepared.
+func (s *Scanner) ScanDirectory(ctx context.Context, dirConfig *config.MonitoredDirectoryConfig) error {
+	start := time.Now()
+	paths := make(chan string, 1000)
+	var totalCount int64
+
+	walkErrCh := make(chan error, 1)
+	go func() {
+		defer close(paths)
+		walkErrCh <- filepath.WalkDir(dirConfig.Path, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if !dirConfig.Recursive && path != dirConfig.Path {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !isMediaFile(path) {
+				return nil
+			}
+			atomic.AddInt64(&totalCount, 1)
+			select {
+			case paths <- path:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			return nil
+		})
+	}()
+
+	results := make(chan database.MediaFileSync, batchSize)
+	var scannedCount int64
+	var wg sync.WaitGroup
+	wg.Add(s.workers)
+	for i := 0; i < s.workers; i++ {
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				if ctx.Err() != nil {
+					continue
+				}
+				item, ok, err := s.prepareSync(path)
+				n := atomic.AddInt64(&scannedCount, 1)
+				if err != nil {
+					slog.Error("Failed to prepare file for sync", "path", path, "error", err)
+				} else if ok {
+					results <- item
+				}
+				s.emitProgress(path, int(n), int(atomic.LoadInt64(&totalCount)), time.Since(start))
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	writeErr := s.writeBatches(results)
+
+	if err := <-walkErrCh; err != nil && err != ctx.Err() {
+		if writeErr == nil {
+			writeErr = fmt.Errorf("error walking directory %s: %w", dirConfig.Path, err)
 		}
-		if info.IsDir() {
-			if !dirConfig.Recursive && path != dirConfig.Path {
-				return filepath.SkipDir
+	}
+	return writeErr
+}
+
+// writeBatches commits prepared rows in groups of batchSize, then syncs
+// each committed row's tags (cheap enough to stay outside the batch
+// transaction; see database.ReplaceItemTags).
+func (s *Scanner) writeBatches(results <-chan database.MediaFileSync) error {
+	batch := make([]database.MediaFileSync, 0, batchSize)
+	var firstErr error
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := s.db.BatchSyncMediaFiles(batch); err != nil {
+			slog.Error("Failed to write media file batch", "count", len(batch), "error", err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to write batch: %w", err)
+			}
+		} else {
+			for _, item := range batch {
+				if err := s.db.ReplaceItemTags(item.File.ID, "media_file", item.Tags); err != nil {
+					slog.Error("Failed to sync tags for file", "path", item.File.Path, "error", err)
+				}
 			}
-			return nil // Continue walking
 		}
+		batch = batch[:0]
+	}
 
-		// Check if it's a media file
-		if !isMediaFile(path) {
-			return nil
+	for item := range results {
+		batch = append(batch, item)
+		if len(batch) >= batchSize {
+			flush()
 		}
+	}
+	flush()
+	return firstErr
+}
+
+// emitProgress sends a ProgressEvent, dropping it if Progress() isn't being
+// drained so a scan never blocks on progress reporting.
+func (s *Scanner) emitProgress(path string, scanned, total int, elapsed time.Duration) {
+	select {
+	case s.progress <- ProgressEvent{Path: path, ScannedCount: scanned, TotalCount: total, ElapsedMs: elapsed.Milliseconds()}:
+	default:
+	}
+}
 
-		// Sync file with database
-		return s.SyncFile(path, info)
-	})
+// prepareSync stats path and builds the row it needs written, without
+// touching the database write path itself — used by the parallel scan's
+// worker goroutines. ok is false when the file is already up to date and
+// needs no write.
+func (s *Scanner) prepareSync(path string) (database.MediaFileSync, bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return database.MediaFileSync{}, false, fmt.Errorf("error statting file: %w", err)
+	}
+	return s.prepareSyncWithInfo(path, info)
 }
 
-// SyncFile checks a single file against the database and adds/updates it if necessary.
-func (s *Scanner) SyncFile(path string, info os.FileInfo) error {
+func (s *Scanner) prepareSyncWithInfo(path string, info os.FileInfo) (database.MediaFileSync, bool, error) {
 	existing, err := s.db.GetFileByPath(path)
 	if err != nil {
-		return fmt.Errorf("error getting file from db: %w", err)
+		return database.MediaFileSync{}, false, fmt.Errorf("error getting file from db: %w", err)
 	}
 
+	if existing != nil && !info.ModTime().After(existing.Modified) && info.Size() == existing.Size {
+		return database.MediaFileSync{}, false, nil
+	}
+
+	folderID, err := s.db.GetOrCreateFolder(filepath.Dir(path))
+	if err != nil {
+		return database.MediaFileSync{}, false, fmt.Errorf("error resolving folder for %s: %w", path, err)
+	}
+
+	mf := buildMediaFile(path, info)
+	mf.FolderID = sql.NullInt64{Int64: folderID, Valid: true}
+	tags := buildTagMap(mf)
+	mf.Tags = marshalTags(tags)
+
 	if existing != nil {
-		// File exists, check if it needs an update
-		if info.ModTime().After(existing.Modified) || info.Size() != existing.Size {
-			slog.Debug("Updating existing file in database", "path", path)
-			mf := buildMediaFile(path, info)
-			mf.ID = existing.ID
-			mf.CreatedAt = existing.CreatedAt
-			return s.db.UpdateMediaFile(mf)
+		slog.Debug("Updating existing file in database", "path", path)
+		mf.ID = existing.ID
+		mf.CreatedAt = existing.CreatedAt
+		return database.MediaFileSync{File: mf, IsNew: false, Tags: tags}, true, nil
+	}
+	slog.Debug("Adding new file to database", "path", path)
+	return database.MediaFileSync{File: mf, IsNew: true, Tags: tags}, true, nil
+}
+
+// SyncFile checks a single file against the database and writes it
+// immediately if it's new or changed. This is the file watcher's per-event
+// path; the parallel directory scan instead uses prepareSyncWithInfo plus
+// the batched writer in writeBatches.
+func (s *Scanner) SyncFile(path string, info os.FileInfo) error {
+	item, ok, err := s.prepareSyncWithInfo(path, info)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+
+	if item.IsNew {
+		id, err := s.db.StoreMediaFile(item.File)
+		if err != nil {
+			return err
 		}
-	} else {
-		// New file
-		slog.Debug("Adding new file to database", "path", path)
-		mf := buildMediaFile(path, info)
-		_, err := s.db.StoreMediaFile(mf)
+		item.File.ID = id
+	} else if err := s.db.UpdateMediaFile(item.File); err != nil {
 		return err
 	}
-	return nil
+	return s.db.ReplaceItemTags(item.File.ID, "media_file", item.Tags)
 }
 
 // cleanup removes files from the database that no longer exist on disk.
@@ -120,19 +311,89 @@ func (s *Scanner) cleanup() error {
 	return nil
 }
 
-// buildMediaFile creates a MediaFile struct from file info.
+// buildMediaFile creates a MediaFile struct from file info, enriched with
+// container tags and stream properties probed from the file itself. Probing
+// failures (e.g. a corrupt file, or ffprobe not installed) are logged and
+// leave the affected fields unset rather than aborting the scan.
 func buildMediaFile(path string, info os.FileInfo) *database.MediaFile {
-	return &database.MediaFile{
+	mimeType := getMimeType(path)
+	mf := &database.MediaFile{
 		Path:       path,
 		ParentPath: filepath.Dir(path),
 		Filename:   info.Name(),
 		Size:       info.Size(),
 		Modified:   info.ModTime(),
-		MimeType:   getMimeType(path),
+		MimeType:   mimeType,
 		Title:      sql.NullString{String: strings.TrimSuffix(info.Name(), filepath.Ext(info.Name())), Valid: true},
 		CreatedAt:  time.Now(),
 		UpdatedAt:  time.Now(),
 	}
+
+	if strings.HasPrefix(mimeType, "video/") || strings.HasPrefix(mimeType, "audio/") {
+		applyMetadata(mf, path)
+	}
+
+	return mf
+}
+
+// applyMetadata probes path with ffprobe and overlays the result onto mf,
+// preferring container tags over the filename-derived title but leaving
+// fields ffprobe couldn't determine untouched.
+func applyMetadata(mf *database.MediaFile, path string) {
+	info, err := metadata.Extract(path)
+	if err != nil {
+		slog.Warn("Failed to extract media metadata", "path", path, "error", err)
+		return
+	}
+
+	if info.Title != "" {
+		mf.Title = sql.NullString{String: info.Title, Valid: true}
+	}
+	if info.Artist != "" {
+		mf.Artist = sql.NullString{String: info.Artist, Valid: true}
+	}
+	if info.Album != "" {
+		mf.Album = sql.NullString{String: info.Album, Valid: true}
+	}
+	if info.AlbumArtist != "" {
+		mf.AlbumArtist = sql.NullString{String: info.AlbumArtist, Valid: true}
+	}
+	if info.Genre != "" {
+		mf.Genre = sql.NullString{String: info.Genre, Valid: true}
+	}
+	if info.Track != 0 {
+		mf.TrackNumber = sql.NullInt32{Int32: info.Track, Valid: true}
+	}
+	if info.Disc != 0 {
+		mf.Disc = sql.NullInt32{Int32: info.Disc, Valid: true}
+	}
+	if info.Year != 0 {
+		mf.Year = sql.NullInt32{Int32: info.Year, Valid: true}
+	}
+	if info.DurationMs != 0 {
+		mf.Duration = sql.NullInt64{Int64: info.DurationMs, Valid: true}
+	}
+	if info.Width != 0 {
+		mf.Width = sql.NullInt32{Int32: info.Width, Valid: true}
+	}
+	if info.Height != 0 {
+		mf.Height = sql.NullInt32{Int32: info.Height, Valid: true}
+	}
+	if info.BitrateKbps != 0 {
+		mf.Bitrate = sql.NullInt32{Int32: info.BitrateKbps, Valid: true}
+	}
+	if info.Channels != 0 {
+		mf.Channels = sql.NullInt32{Int32: info.Channels, Valid: true}
+	}
+	if info.VideoCodec != "" {
+		mf.VideoCodec = sql.NullString{String: info.VideoCodec, Valid: true}
+	}
+	if info.AudioCodec != "" {
+		mf.AudioCodec = sql.NullString{String: info.AudioCodec, Valid: true}
+	}
+	if info.FrameRate != 0 {
+		mf.FrameRate = sql.NullFloat64{Float64: info.FrameRate, Valid: true}
+	}
 }
 
 func isMediaFile(path string) bool {
@@ -0,0 +1,10 @@
+package media
+
+// ProgressEvent reports a parallel scan's progress after each file is
+// prepared, so a UI or log observer can subscribe via Scanner.Progress().
+type ProgressEvent struct {
+	Path         string
+	ScannedCount int
+	TotalCount   int
+	ElapsedMs    int64
+}
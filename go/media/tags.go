@@ -0,0 +1,45 @@
+package media
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"vuio-go/database"
+)
+
+// buildTagMap derives the raw multimap of container tags for a media file
+// from whatever metadata fields are known about it. It is the source for
+// both the media_files.tags JSON column and the normalized tags/item_tags
+// tables used for genre/artist/year browsing.
+//
+// Today this only reflects the single-valued fields already on MediaFile;
+// a future metadata extraction pass can populate true multi-value tags
+// (e.g. multiple genres) without changing how they're stored or queried.
+func buildTagMap(mf *database.MediaFile) map[string][]string {
+	tags := make(map[string][]string)
+	if mf.Genre.Valid && mf.Genre.String != "" {
+		tags["genre"] = []string{mf.Genre.String}
+	}
+	if mf.Artist.Valid && mf.Artist.String != "" {
+		tags["artist"] = []string{mf.Artist.String}
+	}
+	if mf.AlbumArtist.Valid && mf.AlbumArtist.String != "" {
+		tags["album_artist"] = []string{mf.AlbumArtist.String}
+	}
+	if mf.Year.Valid {
+		tags["year"] = []string{strconv.Itoa(int(mf.Year.Int32))}
+	}
+	return tags
+}
+
+// marshalTags JSON-encodes a tag multimap for storage in media_files.tags.
+func marshalTags(tags map[string][]string) string {
+	if len(tags) == 0 {
+		return "{}"
+	}
+	encoded, err := json.Marshal(tags)
+	if err != nil {
+		return "{}"
+	}
+	return string(encoded)
+}
@@ -0,0 +1,174 @@
+// Package metadata extracts container tags and stream properties from media
+// files by shelling out to ffprobe, mirroring the transcode and hls packages'
+// choice to drive ffmpeg's tooling directly rather than add a pure-Go media
+// parsing dependency.
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Info holds the subset of container tags and stream properties the media
+// scanner cares about. Zero values mean "not present" for numeric fields;
+// callers convert them to sql.Null* when populating database.MediaFile.
+type Info struct {
+	Title       string
+	Artist      string
+	Album       string
+	AlbumArtist string
+	Genre       string
+	Track       int32
+	Disc        int32
+	Year        int32
+	DurationMs  int64
+	Width       int32
+	Height      int32
+	BitrateKbps int32
+	Channels    int32
+	VideoCodec  string
+	AudioCodec  string
+	FrameRate   float64
+}
+
+// probeFormat mirrors the subset of ffprobe's JSON output used by Extract.
+type probeFormat struct {
+	Format struct {
+		Duration string            `json:"duration"`
+		BitRate  string            `json:"bit_rate"`
+		Tags     map[string]string `json:"tags"`
+	} `json:"format"`
+	Streams []struct {
+		CodecType  string            `json:"codec_type"`
+		CodecName  string            `json:"codec_name"`
+		Width      int32             `json:"width"`
+		Height     int32             `json:"height"`
+		Channels   int32             `json:"channels"`
+		RFrameRate string            `json:"r_frame_rate"`
+		Tags       map[string]string `json:"tags"`
+	} `json:"streams"`
+}
+
+// Extract runs ffprobe against path and returns its container tags plus
+// video/audio stream properties. It returns an error if ffprobe can't be run
+// or its output can't be parsed; callers should treat that as "no metadata
+// available" rather than fatal, the same way the rest of the scan proceeds
+// when individual files can't be processed.
+func Extract(path string) (Info, error) {
+	out, err := exec.Command("ffprobe", "-v", "error", "-print_format", "json",
+		"-show_format", "-show_streams", path).Output()
+	if err != nil {
+		return Info{}, fmt.Errorf("ffprobe failed for %s: %w", path, err)
+	}
+
+	var probe probeFormat
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return Info{}, fmt.Errorf("failed to parse ffprobe output for %s: %w", path, err)
+	}
+
+	info := Info{}
+	tags := mergedTags(probe.Format.Tags)
+	info.Title = tags["title"]
+	info.Artist = tags["artist"]
+	info.Album = tags["album"]
+	info.AlbumArtist = firstNonEmpty(tags["album_artist"], tags["albumartist"])
+	info.Genre = tags["genre"]
+	info.Track = parseLeadingInt(tags["track"])
+	info.Disc = parseLeadingInt(firstNonEmpty(tags["disc"], tags["discnumber"]))
+	info.Year = parseYear(firstNonEmpty(tags["date"], tags["year"]))
+
+	if seconds, err := strconv.ParseFloat(probe.Format.Duration, 64); err == nil {
+		info.DurationMs = int64(seconds * 1000)
+	}
+	if kbps, err := strconv.ParseInt(probe.Format.BitRate, 10, 64); err == nil {
+		info.BitrateKbps = int32(kbps / 1000)
+	}
+
+	for _, stream := range probe.Streams {
+		switch stream.CodecType {
+		case "video":
+			info.Width = stream.Width
+			info.Height = stream.Height
+			info.VideoCodec = stream.CodecName
+			info.FrameRate = parseFrameRate(stream.RFrameRate)
+		case "audio":
+			info.Channels = stream.Channels
+			info.AudioCodec = stream.CodecName
+			if streamTags := stream.Tags; len(streamTags) > 0 {
+				if info.Title == "" {
+					info.Title = streamTags["title"]
+				}
+				if info.Artist == "" {
+					info.Artist = streamTags["artist"]
+				}
+			}
+		}
+	}
+
+	return info, nil
+}
+
+// mergedTags lowercases ffprobe's tag keys, since containers disagree on
+// case (e.g. "ARTIST" in Vorbis comments vs "artist" in ID3v2).
+func mergedTags(tags map[string]string) map[string]string {
+	merged := make(map[string]string, len(tags))
+	for k, v := range tags {
+		merged[strings.ToLower(k)] = v
+	}
+	return merged
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// parseLeadingInt parses values like "3" or "3/12" (track/disc "N/total"
+// form), returning 0 if s doesn't start with a number.
+func parseLeadingInt(s string) int32 {
+	s = strings.SplitN(s, "/", 2)[0]
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return 0
+	}
+	return int32(n)
+}
+
+// parseYear pulls the four-digit year out of a date tag, which may be a
+// bare year ("2004") or a full date ("2004-05-01").
+func parseYear(s string) int32 {
+	if len(s) < 4 {
+		return 0
+	}
+	n, err := strconv.Atoi(s[:4])
+	if err != nil {
+		return 0
+	}
+	return int32(n)
+}
+
+// parseFrameRate converts ffprobe's r_frame_rate fraction string (e.g.
+// "24000/1001" or "25/1") into frames per second, returning 0 if s is
+// missing or malformed.
+func parseFrameRate(s string) float64 {
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 {
+		return 0
+	}
+	num, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return 0
+	}
+	den, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil || den == 0 {
+		return 0
+	}
+	return num / den
+}
@@ -6,6 +6,7 @@ import (
 	"net"
 	"os"
 	"runtime"
+	"strings"
 )
 
 // OS represents the operating system type.
@@ -127,4 +128,81 @@ func GetPrimaryIP() (string, error) {
 	}
 
 	return "", fmt.Errorf("no suitable network interface found")
+}
+
+// defaultExcludedInterfacePrefixes lists interface name prefixes for virtual
+// adapters (container bridges, VPN tunnels) that shouldn't receive SSDP
+// traffic unless explicitly named in config.
+var defaultExcludedInterfacePrefixes = []string{"docker", "br-", "veth", "virbr", "tun", "tap"}
+
+// MulticastInterface is a network interface selected for multicast use,
+// carrying whichever IPv4 and/or IPv6 address it has bound.
+type MulticastInterface struct {
+	Name  string
+	Iface net.Interface
+	IPv4  net.IP
+	IPv6  net.IP
+}
+
+// SelectMulticastInterfaces returns the interfaces that multicast services
+// like SSDP should bind to. If names is non-empty, only interfaces with a
+// matching name are selected. Otherwise every up interface that supports
+// multicast is selected, excluding loopback and common virtual adapters
+// (docker bridges, veth pairs, VPN tunnels).
+func SelectMulticastInterfaces(names []string) ([]MulticastInterface, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	allow := make(map[string]bool, len(names))
+	for _, name := range names {
+		allow[name] = true
+	}
+
+	var result []MulticastInterface
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagMulticast == 0 {
+			continue
+		}
+		if len(allow) > 0 {
+			if !allow[iface.Name] {
+				continue
+			}
+		} else if iface.Flags&net.FlagLoopback != 0 || isExcludedInterfaceName(iface.Name) {
+			continue
+		}
+
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+
+		mi := MulticastInterface{Name: iface.Name, Iface: iface}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok || ipNet.IP.IsLoopback() || ipNet.IP.IsLinkLocalUnicast() {
+				continue
+			}
+			if ipNet.IP.To4() != nil {
+				mi.IPv4 = ipNet.IP
+			} else if mi.IPv6 == nil {
+				mi.IPv6 = ipNet.IP
+			}
+		}
+		if mi.IPv4 == nil && mi.IPv6 == nil {
+			continue
+		}
+		result = append(result, mi)
+	}
+	return result, nil
+}
+
+func isExcludedInterfaceName(name string) bool {
+	for _, prefix := range defaultExcludedInterfacePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
 }
\ No newline at end of file
@@ -0,0 +1,253 @@
+package playlist
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"vuio-go/database"
+)
+
+// Scanner discovers M3U/PLS/CUE playlist files and resolves their entries
+// against the media library.
+type Scanner struct {
+	db database.Manager
+}
+
+// NewScanner creates a new playlist scanner.
+func NewScanner(db database.Manager) *Scanner {
+	return &Scanner{db: db}
+}
+
+// ScanDirectory walks dir looking for playlist files and syncs each one that
+// is new or has changed since the last scan.
+func (s *Scanner) ScanDirectory(dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !IsPlaylistFile(path) {
+			return nil
+		}
+		return s.SyncFile(path, info)
+	})
+}
+
+// SyncFile parses a playlist file and upserts it, and its resolved tracks,
+// into the database. Playlists whose mtime hasn't advanced are skipped.
+func (s *Scanner) SyncFile(path string, info os.FileInfo) error {
+	existing, err := s.db.GetPlaylistByPath(path)
+	if err != nil {
+		return fmt.Errorf("error getting playlist from db: %w", err)
+	}
+	if existing != nil && !info.ModTime().After(existing.Modified) {
+		return nil
+	}
+
+	entries, err := parsePlaylistFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to parse playlist %s: %w", path, err)
+	}
+
+	tracks := make([]database.PlaylistTrack, 0, len(entries))
+	for i, entry := range entries {
+		track := database.PlaylistTrack{Position: i, RawEntry: entry.Raw}
+		if entry.DurationSeconds > 0 {
+			track.DurationMs = sql.NullInt64{Int64: int64(entry.DurationSeconds) * 1000, Valid: true}
+		}
+		if mf, err := s.resolveEntry(path, entry.Target); err == nil && mf != nil {
+			track.MediaFileID = sql.NullInt64{Int64: mf.ID, Valid: true}
+		}
+		tracks = append(tracks, track)
+	}
+
+	name := strings.TrimSuffix(info.Name(), filepath.Ext(info.Name()))
+	pl := &database.Playlist{Path: path, Name: name, Modified: info.ModTime()}
+	_, err = s.db.UpsertPlaylist(pl, tracks)
+	return err
+}
+
+// resolveEntry matches a playlist entry against media_files, trying the
+// absolute path, then the path relative to the playlist, then falling back
+// to a filename-only match against the library.
+func (s *Scanner) resolveEntry(playlistPath, target string) (*database.MediaFile, error) {
+	if target == "" {
+		return nil, nil
+	}
+
+	if filepath.IsAbs(target) {
+		if mf, err := s.db.GetFileByPath(filepath.Clean(target)); err == nil && mf != nil {
+			return mf, nil
+		}
+	}
+
+	relPath := filepath.Clean(filepath.Join(filepath.Dir(playlistPath), target))
+	if mf, err := s.db.GetFileByPath(relPath); err == nil && mf != nil {
+		return mf, nil
+	}
+
+	return s.resolveByFilename(filepath.Base(target))
+}
+
+// resolveByFilename is a last-resort match for entries whose referenced path
+// no longer exists (e.g. the library was moved to a new mount point).
+func (s *Scanner) resolveByFilename(filename string) (*database.MediaFile, error) {
+	paths, err := s.db.GetAllPaths()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range paths {
+		if filepath.Base(p) == filename {
+			return s.db.GetFileByPath(p)
+		}
+	}
+	return nil, nil
+}
+
+// entry is a single parsed playlist line before database resolution.
+type entry struct {
+	Target          string
+	DurationSeconds int
+	Raw             string
+}
+
+func parsePlaylistFile(path string) ([]entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".m3u", ".m3u8":
+		return parseM3U(f)
+	case ".pls":
+		return parsePLS(f)
+	case ".cue":
+		return parseCUE(f)
+	default:
+		return nil, fmt.Errorf("unsupported playlist extension %q", filepath.Ext(path))
+	}
+}
+
+func parseM3U(r io.Reader) ([]entry, error) {
+	var entries []entry
+	var pendingDuration int
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "#EXTINF:"):
+			pendingDuration = parseExtinfDuration(line)
+		case strings.HasPrefix(line, "#"):
+			continue
+		default:
+			entries = append(entries, entry{Target: line, DurationSeconds: pendingDuration, Raw: line})
+			pendingDuration = 0
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// parseExtinfDuration parses the seconds field of "#EXTINF:<duration>,<title>".
+func parseExtinfDuration(line string) int {
+	rest := strings.TrimPrefix(line, "#EXTINF:")
+	secsPart := strings.SplitN(rest, ",", 2)[0]
+	secs, err := strconv.Atoi(strings.TrimSpace(secsPart))
+	if err != nil {
+		return 0
+	}
+	return secs
+}
+
+func parsePLS(r io.Reader) ([]entry, error) {
+	files := make(map[int]string)
+	lengths := make(map[int]int)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(key, "File"):
+			if idx, err := strconv.Atoi(strings.TrimPrefix(key, "File")); err == nil {
+				files[idx] = value
+			}
+		case strings.HasPrefix(key, "Length"):
+			if idx, err := strconv.Atoi(strings.TrimPrefix(key, "Length")); err == nil {
+				secs, _ := strconv.Atoi(value)
+				lengths[idx] = secs
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	indices := make([]int, 0, len(files))
+	for idx := range files {
+		indices = append(indices, idx)
+	}
+	sort.Ints(indices)
+
+	entries := make([]entry, 0, len(indices))
+	for _, idx := range indices {
+		entries = append(entries, entry{Target: files[idx], DurationSeconds: lengths[idx], Raw: files[idx]})
+	}
+	return entries, nil
+}
+
+// parseCUE reads a CUE sheet's FILE directive and emits one entry per TRACK,
+// all pointing at that same referenced audio file.
+func parseCUE(r io.Reader) ([]entry, error) {
+	var target string
+	var entries []entry
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "FILE "):
+			target = extractQuoted(line)
+		case strings.HasPrefix(line, "TRACK "):
+			if target != "" {
+				entries = append(entries, entry{Target: target, Raw: line})
+			}
+		}
+	}
+	return entries, scanner.Err()
+}
+
+func extractQuoted(line string) string {
+	start := strings.Index(line, `"`)
+	if start == -1 {
+		return ""
+	}
+	end := strings.LastIndex(line, `"`)
+	if end <= start {
+		return ""
+	}
+	return line[start+1 : end]
+}
+
+// IsPlaylistFile reports whether path has a recognized playlist extension.
+func IsPlaylistFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".m3u", ".m3u8", ".pls", ".cue":
+		return true
+	default:
+		return false
+	}
+}
@@ -5,17 +5,22 @@ import (
 	"fmt"
 	"log/slog"
 	"net"
+	"strconv"
 	"strings"
 	"time"
 
 	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+
 	"vuio-go/platform"
 	"vuio-go/state"
 )
 
 const (
-	ssdpMulticastAddr = "239.255.255.250:1900"
-	maxDatagramSize   = 8192
+	ssdpPort            = 1900
+	ssdpMulticastAddrV4 = "239.255.255.250:1900"
+	ssdpMulticastAddrV6 = "[ff05::c]:1900"
+	maxDatagramSize     = 8192
 )
 
 // Service handles SSDP discovery.
@@ -28,67 +33,209 @@ func New(state *state.AppState) (*Service, error) {
 	return &Service{state: state}, nil
 }
 
-// Start begins listening for SSDP messages and sending announcements.
+// Start begins listening for SSDP messages and sending announcements. It
+// joins the multicast group on every selected network interface so
+// multi-homed hosts (Docker, VPN, dual-NIC servers) are discoverable from
+// every subnet they're attached to, rather than just one primary interface.
 func (s *Service) Start(ctx context.Context) {
 	slog.Info("Starting SSDP service")
 
-	addr, err := net.ResolveUDPAddr("udp4", ssdpMulticastAddr)
+	cfg := s.state.GetConfig()
+	ifaces, err := platform.SelectMulticastInterfaces(cfg.Network.Interfaces)
 	if err != nil {
-		slog.Error("Failed to resolve SSDP multicast address", "error", err)
+		slog.Error("Failed to enumerate network interfaces for SSDP", "error", err)
 		return
 	}
-
-	conn, err := net.ListenMulticastUDP("udp4", nil, addr)
-	if err != nil {
-		slog.Error("Failed to listen on SSDP multicast address", "error", err)
+	if len(ifaces) == 0 {
+		slog.Error("No suitable network interfaces found for SSDP")
 		return
 	}
-	defer conn.Close()
 
-	if err := conn.SetReadBuffer(maxDatagramSize); err != nil {
-		slog.Warn("Failed to set SSDP read buffer size", "error", err)
+	pc4, err4 := joinIPv4Group(ifaces)
+	if err4 != nil {
+		slog.Warn("IPv4 SSDP socket unavailable", "error", err4)
 	}
+	pc6, err6 := joinIPv6Group(ifaces)
+	if err6 != nil {
+		slog.Warn("IPv6 SSDP socket unavailable", "error", err6)
+	}
+	if pc4 == nil && pc6 == nil {
+		slog.Error("Failed to join SSDP multicast group on any address family")
+		return
+	}
+	defer func() {
+		if pc4 != nil {
+			_ = pc4.Close()
+		}
+		if pc6 != nil {
+			_ = pc6.Close()
+		}
+	}()
 
-	// Start listener goroutine
-	go s.listen(ctx, conn)
+	if pc4 != nil {
+		go s.listenV4(ctx, pc4, ifaces)
+	}
+	if pc6 != nil {
+		go s.listenV6(ctx, pc6, ifaces)
+	}
 
-	// Start announcer goroutine
-	go s.announce(ctx)
+	go s.announce(ctx, pc4, pc6, ifaces)
 
 	<-ctx.Done()
 	slog.Info("Stopping SSDP service")
 }
 
-func (s *Service) listen(ctx context.Context, conn *net.UDPConn) {
-	packetConn := ipv4.NewPacketConn(conn)
+// joinIPv4Group opens one shared IPv4 multicast socket and joins the SSDP
+// group on every interface that has an IPv4 address.
+func joinIPv4Group(ifaces []platform.MulticastInterface) (*ipv4.PacketConn, error) {
+	groupAddr, err := net.ResolveUDPAddr("udp4", ssdpMulticastAddrV4)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{Port: ssdpPort})
+	if err != nil {
+		return nil, fmt.Errorf("listen udp4: %w", err)
+	}
+	if err := conn.SetReadBuffer(maxDatagramSize); err != nil {
+		slog.Warn("Failed to set SSDP IPv4 read buffer size", "error", err)
+	}
+
+	pc := ipv4.NewPacketConn(conn)
+	if err := pc.SetControlMessage(ipv4.FlagInterface, true); err != nil {
+		slog.Warn("Failed to enable IPv4 interface control messages", "error", err)
+	}
+
+	joined := 0
+	for _, iface := range ifaces {
+		if iface.IPv4 == nil {
+			continue
+		}
+		ifaceCopy := iface.Iface
+		if err := pc.JoinGroup(&ifaceCopy, groupAddr); err != nil {
+			slog.Warn("Failed to join IPv4 SSDP group on interface", "interface", iface.Name, "error", err)
+			continue
+		}
+		joined++
+	}
+	if joined == 0 {
+		_ = conn.Close()
+		return nil, fmt.Errorf("no interface joined the IPv4 SSDP group")
+	}
+	return pc, nil
+}
+
+// joinIPv6Group opens one shared IPv6 multicast socket and joins the SSDP
+// site-local group on every interface that has an IPv6 address.
+func joinIPv6Group(ifaces []platform.MulticastInterface) (*ipv6.PacketConn, error) {
+	groupAddr, err := net.ResolveUDPAddr("udp6", ssdpMulticastAddrV6)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenUDP("udp6", &net.UDPAddr{Port: ssdpPort})
+	if err != nil {
+		return nil, fmt.Errorf("listen udp6: %w", err)
+	}
+
+	pc := ipv6.NewPacketConn(conn)
+	if err := pc.SetControlMessage(ipv6.FlagInterface, true); err != nil {
+		slog.Warn("Failed to enable IPv6 interface control messages", "error", err)
+	}
+
+	joined := 0
+	for _, iface := range ifaces {
+		if iface.IPv6 == nil {
+			continue
+		}
+		ifaceCopy := iface.Iface
+		if err := pc.JoinGroup(&ifaceCopy, groupAddr); err != nil {
+			slog.Warn("Failed to join IPv6 SSDP group on interface", "interface", iface.Name, "error", err)
+			continue
+		}
+		joined++
+	}
+	if joined == 0 {
+		_ = conn.Close()
+		return nil, fmt.Errorf("no interface joined the IPv6 SSDP group")
+	}
+	return pc, nil
+}
+
+func findInterfaceByIndex(ifaces []platform.MulticastInterface, index int) (platform.MulticastInterface, bool) {
+	for _, iface := range ifaces {
+		if iface.Iface.Index == index {
+			return iface, true
+		}
+	}
+	return platform.MulticastInterface{}, false
+}
+
+func (s *Service) listenV4(ctx context.Context, pc *ipv4.PacketConn, ifaces []platform.MulticastInterface) {
 	buffer := make([]byte, maxDatagramSize)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+			_ = pc.SetReadDeadline(time.Now().Add(1 * time.Second))
+			n, cm, src, err := pc.ReadFrom(buffer)
+			if err != nil {
+				if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+					continue
+				}
+				slog.Error("SSDP IPv4 read error", "error", err)
+				return
+			}
+			ifIndex := 0
+			if cm != nil {
+				ifIndex = cm.IfIndex
+			}
+			iface, ok := findInterfaceByIndex(ifaces, ifIndex)
+			if !ok {
+				continue
+			}
+			s.handleRequest(buffer[:n], src, iface)
+		}
+	}
+}
 
+func (s *Service) listenV6(ctx context.Context, pc *ipv6.PacketConn, ifaces []platform.MulticastInterface) {
+	buffer := make([]byte, maxDatagramSize)
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		default:
-			_ = packetConn.SetReadDeadline(time.Now().Add(1 * time.Second))
-			n, _, src, err := packetConn.ReadFrom(buffer)
+			_ = pc.SetReadDeadline(time.Now().Add(1 * time.Second))
+			n, cm, src, err := pc.ReadFrom(buffer)
 			if err != nil {
 				if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
 					continue
 				}
-				slog.Error("SSDP read error", "error", err)
+				slog.Error("SSDP IPv6 read error", "error", err)
 				return
 			}
-			s.handleRequest(buffer[:n], src)
+			ifIndex := 0
+			if cm != nil {
+				ifIndex = cm.IfIndex
+			}
+			iface, ok := findInterfaceByIndex(ifaces, ifIndex)
+			if !ok {
+				continue
+			}
+			s.handleRequest(buffer[:n], src, iface)
 		}
 	}
 }
 
-func (s *Service) handleRequest(data []byte, src net.Addr) {
+func (s *Service) handleRequest(data []byte, src net.Addr, iface platform.MulticastInterface) {
 	req := string(data)
 	if !strings.HasPrefix(req, "M-SEARCH") {
 		return
 	}
 
-	slog.Debug("Received M-SEARCH request", "from", src.String())
+	slog.Debug("Received M-SEARCH request", "from", src.String(), "interface", iface.Name)
 
 	// Check ST (Search Target) header
 	st := getHeader(req, "ST")
@@ -100,17 +247,14 @@ func (s *Service) handleRequest(data []byte, src net.Addr) {
 		st == "upnp:rootdevice" ||
 		strings.HasPrefix(st, "urn:schemas-upnp-org:device:MediaServer") {
 
-		go s.sendSearchResponse(src)
+		go s.sendSearchResponse(src, iface)
 	}
 }
 
-func (s *Service) sendSearchResponse(dest net.Addr) {
+func (s *Service) sendSearchResponse(dest net.Addr, iface platform.MulticastInterface) {
 	cfg := s.state.GetConfig()
-	serverIP, err := platform.GetPrimaryIP()
-	if err != nil {
-		slog.Error("Could not get primary IP for SSDP response", "error", err)
-		return
-	}
+	serverIP := responseIP(iface, dest)
+	network := udpNetworkFor(dest)
 
 	usnRoot := fmt.Sprintf("uuid:%s::upnp:rootdevice", cfg.Server.UUID)
 	usnServer := fmt.Sprintf("uuid:%s::urn:schemas-upnp-org:device:MediaServer:1", cfg.Server.UUID)
@@ -122,70 +266,135 @@ func (s *Service) sendSearchResponse(dest net.Addr) {
 		buildResponse(serverIP, cfg.Server.Port, "urn:schemas-upnp-org:service:ContentDirectory:1", usnContent),
 	}
 
-	conn, err := net.Dial("udp", dest.String())
+	// Bind the response socket to the interface's own address so it leaves
+	// via that interface rather than whichever one the OS routes to dest by
+	// default.
+	conn, err := net.ListenUDP(network, &net.UDPAddr{IP: net.ParseIP(serverIP)})
 	if err != nil {
-		slog.Error("Failed to dial for SSDP response", "dest", dest.String(), "error", err)
+		slog.Error("Failed to open socket for SSDP response", "dest", dest.String(), "error", err)
 		return
 	}
 	defer conn.Close()
 
+	udpDest, err := net.ResolveUDPAddr(network, dest.String())
+	if err != nil {
+		slog.Error("Failed to resolve SSDP response destination", "dest", dest.String(), "error", err)
+		return
+	}
+
 	for _, res := range responses {
-		_, err := conn.Write([]byte(res))
-		if err != nil {
+		if _, err := conn.WriteToUDP([]byte(res), udpDest); err != nil {
 			slog.Warn("Failed to send SSDP response", "dest", dest.String(), "error", err)
 		}
 		time.Sleep(50 * time.Millisecond) // Stagger responses
 	}
-	slog.Debug("Sent M-SEARCH response", "to", dest.String())
+	slog.Debug("Sent M-SEARCH response", "to", dest.String(), "interface", iface.Name)
+}
+
+// responseIP picks the interface address matching the address family of the
+// requester, so an M-SEARCH arriving over IPv6 gets an IPv6 LOCATION and
+// vice versa.
+func responseIP(iface platform.MulticastInterface, dest net.Addr) string {
+	if udpAddr, ok := dest.(*net.UDPAddr); ok && udpAddr.IP.To4() == nil && iface.IPv6 != nil {
+		return iface.IPv6.String()
+	}
+	if iface.IPv4 != nil {
+		return iface.IPv4.String()
+	}
+	return iface.IPv6.String()
+}
+
+func udpNetworkFor(addr net.Addr) string {
+	if udpAddr, ok := addr.(*net.UDPAddr); ok && udpAddr.IP.To4() == nil {
+		return "udp6"
+	}
+	return "udp4"
 }
 
-func (s *Service) announce(ctx context.Context) {
+func (s *Service) announce(ctx context.Context, pc4 *ipv4.PacketConn, pc6 *ipv6.PacketConn, ifaces []platform.MulticastInterface) {
 	ticker := time.NewTicker(time.Duration(s.state.Config.Network.AnnounceIntervalSeconds) * time.Second)
 	defer ticker.Stop()
 
 	// Announce on startup
-	s.sendAnnouncements()
+	s.sendAnnouncements(pc4, pc6, ifaces)
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			s.sendAnnouncements()
+			s.sendAnnouncements(pc4, pc6, ifaces)
 		}
 	}
 }
 
-func (s *Service) sendAnnouncements() {
+func (s *Service) sendAnnouncements(pc4 *ipv4.PacketConn, pc6 *ipv6.PacketConn, ifaces []platform.MulticastInterface) {
 	slog.Info("Sending SSDP NOTIFY announcements")
 	cfg := s.state.GetConfig()
-	serverIP, err := platform.GetPrimaryIP()
-	if err != nil {
-		slog.Error("Could not get primary IP for SSDP announcement", "error", err)
-		return
-	}
 
 	usnRoot := fmt.Sprintf("uuid:%s::upnp:rootdevice", cfg.Server.UUID)
 	usnServer := fmt.Sprintf("uuid:%s::urn:schemas-upnp-org:device:MediaServer:1", cfg.Server.UUID)
 	usnContent := fmt.Sprintf("uuid:%s::urn:schemas-upnp-org:service:ContentDirectory:1", cfg.Server.UUID)
 
+	for _, iface := range ifaces {
+		if iface.IPv4 != nil && pc4 != nil {
+			s.announceOnInterfaceV4(pc4, iface, cfg.Server.Port, usnRoot, usnServer, usnContent)
+		}
+		if iface.IPv6 != nil && pc6 != nil {
+			s.announceOnInterfaceV6(pc6, iface, cfg.Server.Port, usnRoot, usnServer, usnContent)
+		}
+	}
+}
+
+// announceOnInterfaceV4 sends the NOTIFY set out of the shared IPv4 socket,
+// pinned to one interface, so the announcement's LOCATION and egress NIC
+// both reflect that interface.
+func (s *Service) announceOnInterfaceV4(pc *ipv4.PacketConn, iface platform.MulticastInterface, port uint16, usnRoot, usnServer, usnContent string) {
+	ifaceCopy := iface.Iface
+	if err := pc.SetMulticastInterface(&ifaceCopy); err != nil {
+		slog.Warn("Failed to set IPv4 multicast interface", "interface", iface.Name, "error", err)
+	}
+	dest, err := net.ResolveUDPAddr("udp4", ssdpMulticastAddrV4)
+	if err != nil {
+		slog.Error("Failed to resolve SSDP multicast address", "error", err)
+		return
+	}
+
+	ip := iface.IPv4.String()
 	announcements := []string{
-		buildNotify(serverIP, cfg.Server.Port, "upnp:rootdevice", usnRoot),
-		buildNotify(serverIP, cfg.Server.Port, "urn:schemas-upnp-org:device:MediaServer:1", usnServer),
-		buildNotify(serverIP, cfg.Server.Port, "urn:schemas-upnp-org:service:ContentDirectory:1", usnContent),
+		buildNotify(ip, port, "upnp:rootdevice", usnRoot),
+		buildNotify(ip, port, "urn:schemas-upnp-org:device:MediaServer:1", usnServer),
+		buildNotify(ip, port, "urn:schemas-upnp-org:service:ContentDirectory:1", usnContent),
 	}
+	for _, ann := range announcements {
+		if _, err := pc.WriteTo([]byte(ann), nil, dest); err != nil {
+			slog.Warn("Failed to send SSDP announcement", "interface", iface.Name, "error", err)
+		}
+		time.Sleep(100 * time.Millisecond) // Stagger announcements
+	}
+}
 
-	conn, err := net.Dial("udp", ssdpMulticastAddr)
+// announceOnInterfaceV6 is the IPv6 counterpart of announceOnInterfaceV4.
+func (s *Service) announceOnInterfaceV6(pc *ipv6.PacketConn, iface platform.MulticastInterface, port uint16, usnRoot, usnServer, usnContent string) {
+	ifaceCopy := iface.Iface
+	if err := pc.SetMulticastInterface(&ifaceCopy); err != nil {
+		slog.Warn("Failed to set IPv6 multicast interface", "interface", iface.Name, "error", err)
+	}
+	dest, err := net.ResolveUDPAddr("udp6", ssdpMulticastAddrV6)
 	if err != nil {
-		slog.Error("Failed to dial for SSDP announcement", "error", err)
+		slog.Error("Failed to resolve SSDP multicast address", "error", err)
 		return
 	}
-	defer conn.Close()
 
+	ip := iface.IPv6.String()
+	announcements := []string{
+		buildNotify(ip, port, "upnp:rootdevice", usnRoot),
+		buildNotify(ip, port, "urn:schemas-upnp-org:device:MediaServer:1", usnServer),
+		buildNotify(ip, port, "urn:schemas-upnp-org:service:ContentDirectory:1", usnContent),
+	}
 	for _, ann := range announcements {
-		_, err := conn.Write([]byte(ann))
-		if err != nil {
-			slog.Warn("Failed to send SSDP announcement", "error", err)
+		if _, err := pc.WriteTo([]byte(ann), nil, dest); err != nil {
+			slog.Warn("Failed to send SSDP announcement", "interface", iface.Name, "error", err)
 		}
 		time.Sleep(100 * time.Millisecond) // Stagger announcements
 	}
@@ -208,21 +417,27 @@ func buildResponse(ip string, port uint16, st, usn string) string {
 	return fmt.Sprintf("HTTP/1.1 200 OK\r\n"+
 		"CACHE-CONTROL: max-age=1800\r\n"+
 		"EXT:\r\n"+
-		"LOCATION: http://%s:%d/description.xml\r\n"+
+		"LOCATION: %s\r\n"+
 		"SERVER: VuIO-Go/0.1 UPnP/1.0\r\n"+
 		"ST: %s\r\n"+
 		"USN: %s\r\n\r\n",
-		ip, port, st, usn)
+		locationURL(ip, port), st, usn)
 }
 
 func buildNotify(ip string, port uint16, nt, usn string) string {
 	return fmt.Sprintf("NOTIFY * HTTP/1.1\r\n"+
 		"HOST: 239.255.255.250:1900\r\n"+
 		"CACHE-CONTROL: max-age=1800\r\n"+
-		"LOCATION: http://%s:%d/description.xml\r\n"+
+		"LOCATION: %s\r\n"+
 		"NT: %s\r\n"+
 		"NTS: ssdp:alive\r\n"+
 		"SERVER: VuIO-Go/0.1 UPnP/1.0\r\n"+
 		"USN: %s\r\n\r\n",
-		ip, port, nt, usn)
-}
\ No newline at end of file
+		locationURL(ip, port), nt, usn)
+}
+
+// locationURL formats the LOCATION header value, bracketing IPv6 literals as
+// required in a URL host component.
+func locationURL(ip string, port uint16) string {
+	return fmt.Sprintf("http://%s/description.xml", net.JoinHostPort(ip, strconv.Itoa(int(port))))
+}
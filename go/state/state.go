@@ -1,29 +1,46 @@
 package state
 
 import (
+	"fmt"
+	"strings"
 	"sync"
 	"sync/atomic"
 
 	"vuio-go/config"
 	"vuio-go/database"
+	"vuio-go/hls"
 	"vuio-go/platform"
+	"vuio-go/thumbnail"
+	"vuio-go/transcode"
+	"vuio-go/web/eventing"
 )
 
 // AppState holds the shared state of the application.
 type AppState struct {
-	Config          *config.AppConfig
-	DB              database.Manager
-	Platform        *platform.Platform
-	ContentUpdateID atomic.Uint32
-	mu              sync.RWMutex
+	Config            *config.AppConfig
+	DB                database.Manager
+	Platform          *platform.Platform
+	TranscodeProfiles []database.TranscodeProfile
+	Transcoder        transcode.Transcoder
+	Capabilities      transcode.Capabilities
+	HLS               *hls.Manager
+	Thumbnails        *thumbnail.Generator
+	Events            *eventing.Manager
+	ContentUpdateID   atomic.Uint32
+
+	mu                 sync.RWMutex
+	eventMu            sync.Mutex
+	containerUpdateIDs map[string]uint32
 }
 
 // New creates a new AppState.
 func New(cfg *config.AppConfig, db database.Manager, plat *platform.Platform) *AppState {
 	s := &AppState{
-		Config:   cfg,
-		DB:       db,
-		Platform: plat,
+		Config:             cfg,
+		DB:                 db,
+		Platform:           plat,
+		Events:             eventing.NewManager(),
+		containerUpdateIDs: make(map[string]uint32),
 	}
 	s.ContentUpdateID.Store(1)
 	return s
@@ -51,4 +68,35 @@ func (s *AppState) IncrementUpdateID() uint32 {
 // GetUpdateID returns the current content update ID.
 func (s *AppState) GetUpdateID() uint32 {
 	return s.ContentUpdateID.Load()
+}
+
+// IncrementContainerUpdateID bumps the system-wide UpdateID and records it
+// against containerID, for GENA's per-container ContainerUpdateIDs
+// property. It returns the new system-wide UpdateID.
+//
+// Every caller today passes containerID "0": VuIO has no persistent,
+// stable container IDs yet (see BrowseParams.Filter's doc comment in
+// web/xml.go), only the path-concatenated ObjectID scheme, so there's no
+// real container identity to key this map on. Until that rework lands,
+// ContainerUpdateIDs is a single entry that behaves like a second copy of
+// the system-wide UpdateID, not a genuine per-container change signal.
+func (s *AppState) IncrementContainerUpdateID(containerID string) uint32 {
+	id := s.IncrementUpdateID()
+	s.eventMu.Lock()
+	s.containerUpdateIDs[containerID] = id
+	s.eventMu.Unlock()
+	return id
+}
+
+// ContainerUpdateIDs returns every tracked container's most recent UpdateID
+// as "containerID,updateID" pairs, comma-joined for GENA's
+// ContainerUpdateIDs property.
+func (s *AppState) ContainerUpdateIDs() string {
+	s.eventMu.Lock()
+	defer s.eventMu.Unlock()
+	pairs := make([]string, 0, len(s.containerUpdateIDs))
+	for containerID, updateID := range s.containerUpdateIDs {
+		pairs = append(pairs, fmt.Sprintf("%s,%d", containerID, updateID))
+	}
+	return strings.Join(pairs, ",")
 }
\ No newline at end of file
@@ -0,0 +1,66 @@
+// Package thumbnail generates and caches 160x160 JPEG preview images for
+// media files, served on demand for DLNA renderers that display
+// upnp:albumArtURI alongside a directory listing. Both video frame
+// extraction and photo scaling shell out to ffmpeg, mirroring the transcode
+// and hls packages' choice to drive ffmpeg's tooling directly rather than
+// add a pure-Go image library.
+package thumbnail
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"vuio-go/database"
+)
+
+// Generator produces and caches thumbnails under dir, one JPEG file per
+// media ID sized to sizePx square.
+type Generator struct {
+	dir    string
+	sizePx int
+}
+
+// NewGenerator creates a Generator storing thumbnails under dir.
+func NewGenerator(dir string, sizePx int) (*Generator, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create thumbnail cache dir: %w", err)
+	}
+	return &Generator{dir: dir, sizePx: sizePx}, nil
+}
+
+// Thumbnail returns the on-disk path to file's cached thumbnail, generating
+// it with ffmpeg on first request. The cache key includes file.Modified so
+// a re-scanned (changed) file gets a fresh thumbnail instead of serving a
+// stale one.
+func (g *Generator) Thumbnail(file *database.MediaFile) (string, error) {
+	path := filepath.Join(g.dir, fmt.Sprintf("%d_%d.jpg", file.ID, file.Modified.Unix()))
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+
+	scale := fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2",
+		g.sizePx, g.sizePx, g.sizePx, g.sizePx)
+
+	tmpPath := path + ".tmp"
+	var args []string
+	switch {
+	case strings.HasPrefix(file.MimeType, "video/"):
+		args = []string{"-ss", "00:00:05", "-i", file.Path, "-frames:v", "1", "-vf", scale, "-y", tmpPath}
+	case strings.HasPrefix(file.MimeType, "image/"):
+		args = []string{"-i", file.Path, "-frames:v", "1", "-vf", scale, "-y", tmpPath}
+	default:
+		return "", fmt.Errorf("no thumbnail available for mime type %q", file.MimeType)
+	}
+
+	if err := exec.Command("ffmpeg", args...).Run(); err != nil {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("failed to generate thumbnail for %s: %w", file.Path, err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
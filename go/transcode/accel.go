@@ -0,0 +1,129 @@
+package transcode
+
+import (
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Accel identifies the hardware acceleration backend ffmpeg should use for a
+// transcode, resolved from config.TranscodeConfig.PreferredAccel against the
+// Capabilities detected at startup.
+type Accel string
+
+const (
+	AccelNone         Accel = "none"
+	AccelVAAPI        Accel = "vaapi"
+	AccelQSV          Accel = "qsv"
+	AccelNVENC        Accel = "nvenc"
+	AccelVideoToolbox Accel = "videotoolbox"
+)
+
+// Capabilities records what hardware transcoding support was found on this
+// host at startup, so SelectAccel can resolve "auto" without re-probing on
+// every request.
+type Capabilities struct {
+	FFmpegAvailable bool
+	HWAccels        []string // raw "ffmpeg -hwaccels" output, e.g. "vaapi", "qsv", "cuda"
+	VaapiDevice     string   // non-empty only if the configured device node opened successfully
+}
+
+// DetectCapabilities probes the host for ffmpeg and its available hardware
+// acceleration methods, and checks that vaapiDevice (if configured) actually
+// opens. It never returns an error: a failed probe just yields a
+// Capabilities with nothing usable, and callers fall back to software
+// transcoding.
+func DetectCapabilities(vaapiDevice string) Capabilities {
+	var caps Capabilities
+
+	out, err := exec.Command("ffmpeg", "-hwaccels").Output()
+	if err != nil {
+		slog.Warn("ffmpeg not available or failed to report hwaccels; hardware acceleration disabled", "error", err)
+		return caps
+	}
+	caps.FFmpegAvailable = true
+	caps.HWAccels = parseHWAccels(string(out))
+
+	if vaapiDevice != "" && caps.hasAccel("vaapi") {
+		f, err := os.OpenFile(vaapiDevice, os.O_RDWR, 0)
+		if err != nil {
+			slog.Debug("VAAPI device not usable", "device", vaapiDevice, "error", err)
+		} else {
+			_ = f.Close()
+			caps.VaapiDevice = vaapiDevice
+		}
+	}
+
+	return caps
+}
+
+// parseHWAccels extracts the method names from "ffmpeg -hwaccels" output,
+// which lists one per line under a "Hardware acceleration methods:" header.
+func parseHWAccels(output string) []string {
+	var accels []string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "Hardware acceleration methods") {
+			continue
+		}
+		accels = append(accels, line)
+	}
+	return accels
+}
+
+func (c Capabilities) hasAccel(name string) bool {
+	for _, a := range c.HWAccels {
+		if a == name {
+			return true
+		}
+	}
+	return false
+}
+
+// SelectAccel resolves a config.TranscodeConfig.PreferredAccel value
+// ("auto", "none", or a specific backend name) against caps. "auto" only
+// auto-detects VAAPI, since that's the only backend VuIO can confirm is
+// usable (by opening its render node); QSV/NVENC/VideoToolbox must be
+// requested explicitly, trusting that the operator has verified they work.
+func SelectAccel(preferred string, caps Capabilities) Accel {
+	switch preferred {
+	case "", "auto":
+		if caps.VaapiDevice != "" {
+			return AccelVAAPI
+		}
+		return AccelNone
+	case "vaapi":
+		return AccelVAAPI
+	case "qsv":
+		return AccelQSV
+	case "nvenc":
+		return AccelNVENC
+	case "videotoolbox":
+		return AccelVideoToolbox
+	default:
+		return AccelNone
+	}
+}
+
+// HWAccelArgs returns the ffmpeg flags that select accel, meant to be placed
+// ahead of "-i" in the argument list. vaapiDevice is only used for
+// AccelVAAPI, and an empty vaapiDevice disables VAAPI even if accel
+// requested it.
+func HWAccelArgs(accel Accel, vaapiDevice string) []string {
+	switch accel {
+	case AccelVAAPI:
+		if vaapiDevice == "" {
+			return nil
+		}
+		return []string{"-hwaccel", "vaapi", "-hwaccel_device", vaapiDevice, "-hwaccel_output_format", "vaapi"}
+	case AccelQSV:
+		return []string{"-hwaccel", "qsv"}
+	case AccelNVENC:
+		return []string{"-hwaccel", "cuda"}
+	case AccelVideoToolbox:
+		return []string{"-hwaccel", "videotoolbox"}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,277 @@
+package transcode
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"vuio-go/database"
+)
+
+// CachingTranscoder runs ffmpeg through a bounded on-disk cache keyed by
+// source path, profile, and bitrate, so concurrent requests for the same
+// rendition share one ffmpeg process instead of each spawning their own.
+// Seeked requests bypass the cache, since a seek offset changes the
+// rendered output.
+type CachingTranscoder struct {
+	dir     string
+	maxSize int64
+
+	mu       sync.Mutex
+	inFlight map[string]*cacheJob
+}
+
+// NewCachingTranscoder creates a CachingTranscoder storing rendered output
+// under dir, trimming the oldest entries once their combined size exceeds
+// maxSizeBytes.
+func NewCachingTranscoder(dir string, maxSizeBytes int64) (*CachingTranscoder, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create transcode cache dir: %w", err)
+	}
+	return &CachingTranscoder{
+		dir:      dir,
+		maxSize:  maxSizeBytes,
+		inFlight: make(map[string]*cacheJob),
+	}, nil
+}
+
+// cacheJob tracks a single in-progress (or just-finished) ffmpeg run
+// producing one cache entry.
+type cacheJob struct {
+	tmpPath   string
+	cachePath string
+	done      chan struct{}
+	err       error
+}
+
+func cacheKey(path string, profile database.TranscodeProfile, hwaccelArgs []string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%d|%d|%s", path, profile.ID, profile.BitrateKbps, strings.Join(hwaccelArgs, " "))))
+	return hex.EncodeToString(sum[:])
+}
+
+// Transcode returns a reader over the transcoded output for path under
+// profile. If another request for the identical key is already running,
+// its in-progress output is tailed rather than starting a second ffmpeg
+// process. hwaccelArgs (see HWAccelArgs) is part of the cache key, since a
+// hardware-accelerated rendition isn't guaranteed to produce identical
+// output to a software one.
+func (c *CachingTranscoder) Transcode(ctx context.Context, path string, profile database.TranscodeProfile, seekSeconds float64, hwaccelArgs []string) (io.ReadCloser, error) {
+	if seekSeconds > 0 {
+		return streamingReader(ctx, path, profile, seekSeconds, hwaccelArgs)
+	}
+
+	key := cacheKey(path, profile, hwaccelArgs)
+	cachePath := filepath.Join(c.dir, key)
+
+	c.mu.Lock()
+	if job, ok := c.inFlight[key]; ok {
+		c.mu.Unlock()
+		return tailJob(job)
+	}
+
+	if f, err := os.Open(cachePath); err == nil {
+		c.mu.Unlock()
+		return f, nil
+	}
+
+	tmpPath := cachePath + ".tmp"
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		c.mu.Unlock()
+		return nil, fmt.Errorf("failed to create transcode cache file: %w", err)
+	}
+
+	job := &cacheJob{tmpPath: tmpPath, cachePath: cachePath, done: make(chan struct{})}
+	c.inFlight[key] = job
+	c.mu.Unlock()
+
+	go c.run(context.WithoutCancel(ctx), path, profile, hwaccelArgs, out, key, job)
+
+	return tailJob(job)
+}
+
+// run executes ffmpeg, writing its stdout into the job's temp file. The job
+// runs to completion independent of the request that started it, so a
+// client disconnecting doesn't cut off other requests tailing the same
+// output; Transcode hands it ctx with the request's cancellation removed.
+func (c *CachingTranscoder) run(ctx context.Context, path string, profile database.TranscodeProfile, hwaccelArgs []string, out *os.File, key string, job *cacheJob) {
+	defer close(job.done)
+	defer func() {
+		c.mu.Lock()
+		delete(c.inFlight, key)
+		c.mu.Unlock()
+	}()
+
+	args, err := BuildArgs(profile, path, 0, hwaccelArgs)
+	if err != nil {
+		job.err = err
+		out.Close()
+		os.Remove(job.tmpPath)
+		return
+	}
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	cmd.Stdout = out
+	runErr := cmd.Run()
+	out.Close()
+
+	if runErr != nil {
+		job.err = fmt.Errorf("ffmpeg transcode failed: %w", runErr)
+		os.Remove(job.tmpPath)
+		return
+	}
+
+	if err := os.Rename(job.tmpPath, job.cachePath); err != nil {
+		job.err = err
+		return
+	}
+
+	c.evict()
+}
+
+// evict removes the oldest cached files once the cache directory's total
+// size exceeds maxSize.
+func (c *CachingTranscoder) evict() {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+
+	type cachedFile struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var files []cachedFile
+	var total int64
+	for _, e := range entries {
+		if e.IsDir() || strings.HasSuffix(e.Name(), ".tmp") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, cachedFile{path: filepath.Join(c.dir, e.Name()), size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+	}
+	if total <= c.maxSize {
+		return
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	for _, f := range files {
+		if total <= c.maxSize {
+			break
+		}
+		if err := os.Remove(f.path); err != nil {
+			continue
+		}
+		total -= f.size
+	}
+}
+
+// tailJob opens a reader over a cache job's output. If the job has already
+// finished, the finished file is opened directly; otherwise a tailReader
+// follows the still-growing temp file.
+func tailJob(job *cacheJob) (io.ReadCloser, error) {
+	select {
+	case <-job.done:
+		if job.err != nil {
+			return nil, job.err
+		}
+		return os.Open(job.cachePath)
+	default:
+	}
+
+	f, err := os.Open(job.tmpPath)
+	if err != nil {
+		return nil, err
+	}
+	return &tailReader{f: f, job: job}, nil
+}
+
+// tailReader reads from a file that may still be growing, only reporting
+// EOF once the producing job has finished.
+type tailReader struct {
+	f   *os.File
+	job *cacheJob
+}
+
+func (t *tailReader) Read(p []byte) (int, error) {
+	for {
+		n, err := t.f.Read(p)
+		if n > 0 {
+			return n, nil
+		}
+		if err != nil && err != io.EOF {
+			return n, err
+		}
+
+		select {
+		case <-t.job.done:
+			// The producer may have written its last bytes after our most
+			// recent read; drain once more before reporting EOF.
+			if n, err := t.f.Read(p); n > 0 {
+				return n, nil
+			} else if err != nil && err != io.EOF {
+				return 0, err
+			}
+			if t.job.err != nil {
+				return 0, t.job.err
+			}
+			return 0, io.EOF
+		default:
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+}
+
+func (t *tailReader) Close() error {
+	return t.f.Close()
+}
+
+// streamingReader runs ffmpeg directly, piping stdout back without caching.
+// Used for seeked requests, since a seek offset makes the output specific
+// to that one request.
+func streamingReader(ctx context.Context, path string, profile database.TranscodeProfile, seekSeconds float64, hwaccelArgs []string) (io.ReadCloser, error) {
+	args, err := BuildArgs(profile, path, seekSeconds, hwaccelArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &cmdReadCloser{ReadCloser: stdout, cmd: cmd}, nil
+}
+
+// cmdReadCloser waits for the ffmpeg process to exit on Close, so it
+// doesn't become a zombie when a client disconnects mid-stream.
+type cmdReadCloser struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+}
+
+func (c *cmdReadCloser) Close() error {
+	closeErr := c.ReadCloser.Close()
+	if err := c.cmd.Wait(); err != nil {
+		slog.Debug("ffmpeg process exited with error after stream close", "error", err)
+	}
+	return closeErr
+}
@@ -0,0 +1,101 @@
+package transcode
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"vuio-go/database"
+)
+
+// Transcoder produces a transcoded stream for a source file under a given
+// profile. Implementations may share or cache the underlying ffmpeg process
+// across concurrent requests for the same rendition.
+type Transcoder interface {
+	Transcode(ctx context.Context, path string, profile database.TranscodeProfile, seekSeconds float64, hwaccelArgs []string) (io.ReadCloser, error)
+}
+
+// SelectProfile returns the first profile whose ClientMatch matches
+// userAgent and whose SourceMimePattern matches sourceMime, or nil if none
+// applies. Profiles are tried in the order they're configured.
+func SelectProfile(profiles []database.TranscodeProfile, userAgent, sourceMime string) *database.TranscodeProfile {
+	for i := range profiles {
+		profile := profiles[i]
+
+		clientRe, err := regexp.Compile(profile.ClientMatch)
+		if err != nil || !clientRe.MatchString(userAgent) {
+			continue
+		}
+
+		mimeRe, err := regexp.Compile(profile.SourceMimePattern)
+		if err != nil || !mimeRe.MatchString(sourceMime) {
+			continue
+		}
+
+		return &profiles[i]
+	}
+	return nil
+}
+
+// templateData is exposed to a profile's FFmpegArgsTemplate.
+type templateData struct {
+	Input      string
+	MaxBitRate int
+}
+
+// inputPlaceholder stands in for the source path while the rendered
+// template is tokenized by whitespace, so a path containing spaces ("My
+// Movie (2020).mp4") doesn't get split into multiple bogus ffmpeg
+// arguments. It's substituted back in after tokenizing.
+const inputPlaceholder = "\x00INPUT\x00"
+
+// BuildArgs renders a profile's FFmpegArgsTemplate for the given source
+// path. The rendered template is expected to include the "-i" input flag
+// itself; when seekSeconds is positive, "-ss <seconds>" is prepended so the
+// seek happens on the input side, ahead of "-i". hwaccelArgs (see
+// HWAccelArgs), if any, are placed ahead of everything else, as ffmpeg
+// requires "-hwaccel" flags to precede "-i".
+func BuildArgs(profile database.TranscodeProfile, path string, seekSeconds float64, hwaccelArgs []string) ([]string, error) {
+	tmpl, err := template.New("ffmpeg_args").Parse(profile.FFmpegArgsTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ffmpeg_args_template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	data := templateData{Input: inputPlaceholder, MaxBitRate: profile.BitrateKbps}
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render ffmpeg_args_template: %w", err)
+	}
+
+	fields := strings.Fields(buf.String())
+	args := make([]string, len(fields))
+	for i, field := range fields {
+		args[i] = strings.ReplaceAll(field, inputPlaceholder, path)
+	}
+	if seekSeconds > 0 {
+		args = append([]string{"-ss", fmt.Sprintf("%.3f", seekSeconds)}, args...)
+	}
+	if len(hwaccelArgs) > 0 {
+		args = append(append([]string{}, hwaccelArgs...), args...)
+	}
+	return args, nil
+}
+
+// Stream execs ffmpeg for the given profile and copies its stdout to w. It
+// honors ctx cancellation, so killing ctx (e.g. on client disconnect) stops
+// the ffmpeg process.
+func Stream(ctx context.Context, w io.Writer, path string, profile database.TranscodeProfile, seekSeconds float64, hwaccelArgs []string) error {
+	args, err := BuildArgs(profile, path, seekSeconds, hwaccelArgs)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	cmd.Stdout = w
+	return cmd.Run()
+}
@@ -0,0 +1,22 @@
+//go:build linux || darwin
+
+package watcher
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileInode returns path's inode number, used to correlate a Rename event
+// with a subsequent Create event into a move.
+func fileInode(path string) (uint64, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, false
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(stat.Ino), true
+}
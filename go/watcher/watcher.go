@@ -5,76 +5,107 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+	"vuio-go/config"
 	"vuio-go/media"
+	"vuio-go/playlist"
 	"vuio-go/state"
 )
 
 // Watcher monitors the filesystem for changes.
 type Watcher struct {
-	state   *state.AppState
-	scanner *media.Scanner
+	state           *state.AppState
+	scanner         *media.Scanner
+	playlistScanner *playlist.Scanner
+	fsWatcher       *fsnotify.Watcher
+	dirConfigs      []config.MonitoredDirectoryConfig
+
+	mu          sync.Mutex
+	watchedDirs map[string]bool // live set of directories under fsnotify watch
+	fileInodes  map[string]uint64
+
+	pending    map[string]fsnotify.Event // path -> latest event of the in-flight batch
+	batchTimer *time.Timer
+
+	eventTimes    []time.Time
+	safeguardOn   bool
+	safeguardStop chan struct{}
 }
 
 // New creates a new filesystem watcher.
 func New(state *state.AppState) (*Watcher, error) {
 	return &Watcher{
-		state:   state,
-		scanner: media.NewScanner(state.DB),
+		state:           state,
+		scanner:         media.NewScanner(state.DB),
+		playlistScanner: playlist.NewScanner(state.DB),
+		watchedDirs:     make(map[string]bool),
+		fileInodes:      make(map[string]uint64),
 	}, nil
 }
 
 // Start begins watching the configured media directories.
 func (w *Watcher) Start(ctx context.Context) {
-	watcher, err := fsnotify.NewWatcher()
+	fsWatcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		slog.Error("Failed to create fsnotify watcher", "error", err)
 		return
 	}
-	defer watcher.Close()
+	defer fsWatcher.Close()
+	w.fsWatcher = fsWatcher
 
-	for _, dir := range w.state.GetConfig().Media.Directories {
-		slog.Info("Adding directory to watcher", "path", dir.Path)
-		err := filepath.Walk(dir.Path, func(path string, info os.FileInfo, err error) error {
-			if info.IsDir() {
-				return watcher.Add(path)
-			}
-			return nil
-		})
-		if err != nil {
-			slog.Error("Failed to add path to watcher", "path", dir.Path, "error", err)
+	cfg := w.state.GetConfig()
+	w.dirConfigs = cfg.Media.Directories
+
+	watched := make(map[string]bool, len(cfg.Media.Directories))
+	for _, dir := range cfg.Media.Directories {
+		watched[dir.Path] = true
+		w.addTree(dir.Path, dir.ExcludePatterns, false)
+	}
+	for _, root := range cfg.GetMediaRoots() {
+		if watched[root.Path] {
+			continue
 		}
+		watched[root.Path] = true
+		w.addTree(root.Path, nil, false)
 	}
 
-	slog.Info("Filesystem watcher started")
+	debounce := time.Duration(cfg.Media.DebounceSeconds * float64(time.Second))
+	if debounce <= 0 {
+		debounce = 2 * time.Second
+	}
+	safeguardWindow := time.Duration(cfg.Media.SafeguardWindowSeconds * float64(time.Second))
+	if safeguardWindow <= 0 {
+		safeguardWindow = 10 * time.Second
+	}
+	safeguardThreshold := cfg.Media.SafeguardEventThreshold
+	if safeguardThreshold <= 0 {
+		safeguardThreshold = 500
+	}
 
-	// Debounce events
-	var (
-		timer  *time.Timer
-		events []fsnotify.Event
-	)
-	debounceDuration := 2 * time.Second
+	slog.Info("Filesystem watcher started",
+		"debounce", debounce, "safeguard_threshold", safeguardThreshold, "safeguard_window", safeguardWindow)
 
 	for {
 		select {
 		case <-ctx.Done():
 			slog.Info("Stopping filesystem watcher")
+			w.stopSafeguard()
 			return
-		case event, ok := <-watcher.Events:
+		case event, ok := <-fsWatcher.Events:
 			if !ok {
 				return
 			}
-			events = append(events, event)
-			if timer != nil {
-				timer.Stop()
+			if w.noteEvent(ctx, safeguardWindow, safeguardThreshold) {
+				// Safeguard is active; periodic full rescans are handling
+				// sync instead of per-event debouncing.
+				continue
 			}
-			timer = time.AfterFunc(debounceDuration, func() {
-				w.handleEvents(events)
-				events = nil // Clear events
-			})
-		case err, ok := <-watcher.Errors:
+			w.scheduleBatch(event, debounce)
+		case err, ok := <-fsWatcher.Errors:
 			if !ok {
 				return
 			}
@@ -83,49 +114,399 @@ func (w *Watcher) Start(ctx context.Context) {
 	}
 }
 
-func (w *Watcher) handleEvents(events []fsnotify.Event) {
-	slog.Debug("Handling debounced filesystem events", "count", len(events))
-	// A simple approach is to just re-scan changed files.
-	// The Rust code has more complex logic to handle moves vs create/delete.
-	changedPaths := make(map[string]fsnotify.Event)
-	for _, event := range events {
-		changedPaths[event.Name] = event
+// addTree walks root, adding every subdirectory not matching
+// excludePatterns to the live fsnotify watch set and caching the inode of
+// every file found so a later Rename of it can be correlated with its
+// matching Create by inode instead of being treated as a delete+insert.
+//
+// syncFiles additionally syncs each file into the library as it's walked.
+// That pass only belongs to the runtime "new directory appeared" path
+// (handleCreateOrWrite), for a directory that arrives already populated
+// (e.g. a download client's completed-folder move), since that fires a
+// single Create on the top-level directory with no per-file events of its
+// own. Start's initial walk over every configured directory passes false,
+// since media.Scanner's startup scan already populates the library for
+// those directories; syncing here too would serially re-walk and re-insert
+// the whole library on every restart.
+func (w *Watcher) addTree(root string, excludePatterns []string, syncFiles bool) {
+	slog.Info("Adding directory to watcher", "path", root)
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if p != root && matchesExcludePattern(p, excludePatterns) {
+				return filepath.SkipDir
+			}
+			if err := w.fsWatcher.Add(p); err != nil {
+				slog.Error("Failed to add path to watcher", "path", p, "error", err)
+				return nil
+			}
+			w.mu.Lock()
+			w.watchedDirs[p] = true
+			w.mu.Unlock()
+			return nil
+		}
+		if matchesExcludePattern(p, excludePatterns) {
+			return nil
+		}
+		if inode, ok := fileInode(p); ok {
+			w.mu.Lock()
+			w.fileInodes[p] = inode
+			w.mu.Unlock()
+		}
+		if syncFiles {
+			w.syncFile(p, info)
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error("Failed to walk directory for watcher", "path", root, "error", err)
 	}
+}
 
-	contentChanged := false
-	for path, event := range changedPaths {
-		switch {
-		case event.Op&fsnotify.Create != 0:
-			slog.Info("File created", "path", path)
-			info, err := os.Stat(path)
-			if err == nil {
-				if info.IsDir() {
-					// In a real implementation, we'd add this new dir to the watcher.
-					// For now, we'll rely on the initial recursive walk.
-				} else {
-					if err := w.scanner.syncFile(path, info); err == nil {
-						contentChanged = true
-					}
-				}
+// removeTree drops every watched directory under (and including) root from
+// the live fsnotify watch set and its files' cached inodes, then
+// bulk-deletes the media files that were under it. Used when a watched
+// directory is itself removed or renamed away.
+func (w *Watcher) removeTree(root string) {
+	w.mu.Lock()
+	var dirs []string
+	for p := range w.watchedDirs {
+		if p == root || strings.HasPrefix(p, root+string(filepath.Separator)) {
+			dirs = append(dirs, p)
+		}
+	}
+	for _, p := range dirs {
+		delete(w.watchedDirs, p)
+	}
+	for p := range w.fileInodes {
+		if p == root || strings.HasPrefix(p, root+string(filepath.Separator)) {
+			delete(w.fileInodes, p)
+		}
+	}
+	w.mu.Unlock()
+
+	for _, p := range dirs {
+		if err := w.fsWatcher.Remove(p); err != nil {
+			slog.Debug("Failed to remove watched path", "path", p, "error", err)
+		}
+	}
+
+	count, err := w.state.DB.RemoveMediaFilesUnder(root)
+	if err != nil {
+		slog.Error("Failed to remove media files under removed directory", "path", root, "error", err)
+		return
+	}
+	if count > 0 {
+		slog.Info("Removed media files for deleted directory", "path", root, "count", count)
+	}
+}
+
+// excludePatternsFor returns the ExcludePatterns of the configured
+// directory containing path (the longest matching configured path), so a
+// newly-created subdirectory inherits its root's exclusions.
+func (w *Watcher) excludePatternsFor(path string) []string {
+	var best string
+	var patterns []string
+	for _, dir := range w.dirConfigs {
+		if (path == dir.Path || strings.HasPrefix(path, dir.Path+string(filepath.Separator))) && len(dir.Path) > len(best) {
+			best = dir.Path
+			patterns = dir.ExcludePatterns
+		}
+	}
+	return patterns
+}
+
+// matchesExcludePattern reports whether path's base name matches any of the
+// configured glob patterns (e.g. ".*", "*.tmp").
+func matchesExcludePattern(path string, patterns []string) bool {
+	name := filepath.Base(path)
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// noteEvent records an event in the rolling safeguard window, pruning
+// entries older than window, and engages the safeguard if the count within
+// the window exceeds threshold. It returns whether the safeguard is active
+// (either just engaged or already running).
+func (w *Watcher) noteEvent(ctx context.Context, window time.Duration, threshold int) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now()
+	w.eventTimes = append(w.eventTimes, now)
+	w.eventTimes = prune(w.eventTimes, now.Add(-window))
+
+	if !w.safeguardOn && len(w.eventTimes) > threshold {
+		slog.Warn("Filesystem event rate exceeded safeguard threshold, switching to periodic full rescans",
+			"events", len(w.eventTimes), "window", window)
+		w.safeguardOn = true
+		w.safeguardStop = make(chan struct{})
+		go w.runSafeguardRescans(ctx, window, threshold)
+	}
+	return w.safeguardOn
+}
+
+func prune(times []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(times) && times[i].Before(cutoff) {
+		i++
+	}
+	return times[i:]
+}
+
+// runSafeguardRescans performs a full rescan every window until the event
+// rate has dropped back under threshold, then hands control back to the
+// normal debounced batch handling.
+func (w *Watcher) runSafeguardRescans(ctx context.Context, window time.Duration, threshold int) {
+	ticker := time.NewTicker(window)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.safeguardStop:
+			return
+		case <-ticker.C:
+			slog.Info("Safeguard active, performing periodic full rescan")
+			cfg := w.state.GetConfig()
+			if err := w.scanner.ScanAllDirectories(ctx, cfg); err != nil {
+				slog.Error("Periodic safeguard rescan failed", "error", err)
 			}
-		case event.Op&fsnotify.Write != 0:
-			slog.Info("File modified", "path", path)
-			info, err := os.Stat(path)
-			if err == nil {
-				if err := w.scanner.syncFile(path, info); err == nil {
-					contentChanged = true
+			for _, dir := range cfg.Media.Directories {
+				if err := w.playlistScanner.ScanDirectory(dir.Path); err != nil {
+					slog.Error("Periodic safeguard playlist rescan failed", "path", dir.Path, "error", err)
 				}
 			}
-		case event.Op&fsnotify.Remove != 0 || event.Op&fsnotify.Rename != 0:
-			slog.Info("File removed/renamed", "path", path)
-			if _, err := w.state.DB.RemoveMediaFile(path); err == nil {
-				contentChanged = true
+			// "0" is a placeholder, not a real container ID; see
+			// IncrementContainerUpdateID's doc comment.
+			newID := w.state.IncrementContainerUpdateID("0")
+			w.state.Events.Notify(newID, w.state.ContainerUpdateIDs())
+
+			w.mu.Lock()
+			w.eventTimes = prune(w.eventTimes, time.Now().Add(-window))
+			stillHot := len(w.eventTimes) > threshold
+			if !stillHot {
+				w.safeguardOn = false
+			}
+			w.mu.Unlock()
+
+			if !stillHot {
+				slog.Info("Filesystem event rate subsided, resuming normal debounced watching")
+				return
 			}
 		}
 	}
+}
+
+// stopSafeguard stops any in-flight periodic rescan loop, used on shutdown.
+func (w *Watcher) stopSafeguard() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.safeguardOn && w.safeguardStop != nil {
+		close(w.safeguardStop)
+		w.safeguardOn = false
+	}
+}
+
+// scheduleBatch records event as the latest pending event for its path and
+// (re)starts the shared batch timer. Batching every path together (rather
+// than debouncing each path independently) lets flushBatch see a Rename and
+// its matching Create side by side, so it can correlate them into a move.
+func (w *Watcher) scheduleBatch(event fsnotify.Event, debounce time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.pending == nil {
+		w.pending = make(map[string]fsnotify.Event)
+	}
+	w.pending[event.Name] = event
+
+	if w.batchTimer != nil {
+		w.batchTimer.Stop()
+	}
+	w.batchTimer = time.AfterFunc(debounce, w.flushBatch)
+}
+
+// flushBatch processes the pending batch once the filesystem has gone
+// quiet, bumping the UpdateID once for the whole batch rather than once per
+// event.
+func (w *Watcher) flushBatch() {
+	w.mu.Lock()
+	batch := w.pending
+	w.pending = nil
+	w.mu.Unlock()
+	if len(batch) == 0 {
+		return
+	}
+
+	events := make([]fsnotify.Event, 0, len(batch))
+	for _, event := range batch {
+		events = append(events, event)
+	}
 
-	if contentChanged {
-		newID := w.state.IncrementUpdateID()
+	if w.handleBatch(events) {
+		// "0" is a placeholder, not a real container ID; see
+		// IncrementContainerUpdateID's doc comment.
+		newID := w.state.IncrementContainerUpdateID("0")
 		slog.Info("Content updated, new UpdateID", "id", newID)
+		w.state.Events.Notify(newID, w.state.ContainerUpdateIDs())
 	}
-}
\ No newline at end of file
+}
+
+// handleBatch processes one debounced batch of events. It first tries to
+// correlate each Rename with a same-batch Create sharing its cached inode
+// into a single move, preserving the file's database ID (and so any
+// DIDL-Lite URLs already handed out for it) instead of a delete+insert, then
+// handles every remaining event individually. It reports whether anything
+// in the batch actually changed content.
+func (w *Watcher) handleBatch(events []fsnotify.Event) bool {
+	var renames, creates, removes, writes []fsnotify.Event
+	for _, event := range events {
+		switch {
+		case event.Op&fsnotify.Rename != 0:
+			renames = append(renames, event)
+		case event.Op&fsnotify.Create != 0:
+			creates = append(creates, event)
+		case event.Op&fsnotify.Remove != 0:
+			removes = append(removes, event)
+		default:
+			writes = append(writes, event)
+		}
+	}
+
+	moved := make(map[string]bool)
+	changed := false
+
+	for _, rename := range renames {
+		w.mu.Lock()
+		oldInode, known := w.fileInodes[rename.Name]
+		w.mu.Unlock()
+		if !known {
+			continue
+		}
+		for _, create := range creates {
+			if moved[create.Name] {
+				continue
+			}
+			inode, ok := fileInode(create.Name)
+			if !ok || inode != oldInode {
+				continue
+			}
+			if w.handleMove(rename.Name, create.Name) {
+				changed = true
+			}
+			moved[rename.Name] = true
+			moved[create.Name] = true
+			break
+		}
+	}
+
+	for _, rename := range renames {
+		if !moved[rename.Name] && w.handleRemoval(rename.Name) {
+			changed = true
+		}
+	}
+	for _, remove := range removes {
+		if w.handleRemoval(remove.Name) {
+			changed = true
+		}
+	}
+	for _, create := range creates {
+		if !moved[create.Name] && w.handleCreateOrWrite(create.Name) {
+			changed = true
+		}
+	}
+	for _, write := range writes {
+		if w.handleCreateOrWrite(write.Name) {
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// handleMove updates the database and the inode cache for a file that moved
+// from oldPath to newPath within the same batch.
+func (w *Watcher) handleMove(oldPath, newPath string) bool {
+	slog.Info("File moved", "from", oldPath, "to", newPath)
+	if err := w.state.DB.UpdateMediaFilePath(oldPath, newPath); err != nil {
+		slog.Error("Failed to update moved file's path", "from", oldPath, "to", newPath, "error", err)
+		return false
+	}
+
+	w.mu.Lock()
+	if inode, ok := w.fileInodes[oldPath]; ok {
+		delete(w.fileInodes, oldPath)
+		w.fileInodes[newPath] = inode
+	}
+	w.mu.Unlock()
+	return true
+}
+
+// handleRemoval handles a Remove, or a Rename that wasn't matched to a
+// Create elsewhere in the batch. If path was a watched directory, its whole
+// subtree is dropped; otherwise a single media file row is removed.
+func (w *Watcher) handleRemoval(path string) bool {
+	w.mu.Lock()
+	_, wasDir := w.watchedDirs[path]
+	w.mu.Unlock()
+	if wasDir {
+		slog.Info("Directory removed", "path", path)
+		w.removeTree(path)
+		return true
+	}
+
+	slog.Info("File removed/renamed", "path", path)
+	w.mu.Lock()
+	delete(w.fileInodes, path)
+	w.mu.Unlock()
+	_, err := w.state.DB.RemoveMediaFile(path)
+	return err == nil
+}
+
+// handleCreateOrWrite handles a Create or Write event at path: a newly
+// created directory is walked and added to the live watch set (respecting
+// its parent's ExcludePatterns); anything else is synced as a media or
+// playlist file.
+func (w *Watcher) handleCreateOrWrite(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		// Gone by the time the debounce fired (e.g. a rapid
+		// create-then-delete); treat it as a removal.
+		return w.handleRemoval(path)
+	}
+	if info.IsDir() {
+		slog.Info("Directory created", "path", path)
+		w.addTree(path, w.excludePatternsFor(path), true)
+		return true
+	}
+
+	slog.Info("File changed", "path", path)
+	if inode, ok := fileInode(path); ok {
+		w.mu.Lock()
+		w.fileInodes[path] = inode
+		w.mu.Unlock()
+	}
+	return w.syncFile(path, info)
+}
+
+// syncFile routes a changed path to the media scanner or the playlist
+// scanner based on its extension, and reports whether the sync succeeded.
+func (w *Watcher) syncFile(path string, info os.FileInfo) bool {
+	if playlist.IsPlaylistFile(path) {
+		if err := w.playlistScanner.SyncFile(path, info); err != nil {
+			slog.Error("Failed to sync playlist", "path", path, "error", err)
+			return false
+		}
+		return true
+	}
+	if err := w.scanner.SyncFile(path, info); err != nil {
+		slog.Error("Failed to sync media file", "path", path, "error", err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,196 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+	"vuio-go/config"
+	"vuio-go/database"
+	"vuio-go/state"
+)
+
+func TestMatchesExcludePattern(t *testing.T) {
+	cases := []struct {
+		path     string
+		patterns []string
+		want     bool
+	}{
+		{"/media/.hidden", []string{".*"}, true},
+		{"/media/movie.mp4", []string{".*"}, false},
+		{"/media/tmp/file.tmp", []string{"*.tmp"}, true},
+		{"/media/movie.mp4", nil, false},
+		{"/media/show.mkv", []string{"*.tmp", "*.mkv"}, true},
+	}
+	for _, c := range cases {
+		if got := matchesExcludePattern(c.path, c.patterns); got != c.want {
+			t.Errorf("matchesExcludePattern(%q, %v) = %v, want %v", c.path, c.patterns, got, c.want)
+		}
+	}
+}
+
+func TestExcludePatternsFor(t *testing.T) {
+	w := &Watcher{
+		dirConfigs: []config.MonitoredDirectoryConfig{
+			{Path: "/media", ExcludePatterns: []string{"*.tmp"}},
+			{Path: "/media/downloads", ExcludePatterns: []string{"*.part"}},
+		},
+	}
+	if got := w.excludePatternsFor("/media/downloads/movie.part"); len(got) != 1 || got[0] != "*.part" {
+		t.Errorf("excludePatternsFor(downloads subpath) = %v, want the downloads-specific pattern (longest prefix match)", got)
+	}
+	if got := w.excludePatternsFor("/media/movie.tmp"); len(got) != 1 || got[0] != "*.tmp" {
+		t.Errorf("excludePatternsFor(media subpath) = %v, want the top-level pattern", got)
+	}
+	if got := w.excludePatternsFor("/elsewhere/movie.tmp"); got != nil {
+		t.Errorf("excludePatternsFor(unmatched path) = %v, want nil", got)
+	}
+}
+
+// fakeDB is a minimal database.Manager stub recording calls relevant to
+// move correlation and removal, with every other method returning a zero
+// value: the watcher tests below never exercise them.
+type fakeDB struct {
+	updatedPaths [][2]string
+	removed      []string
+}
+
+func (f *fakeDB) Initialize() error                                  { return nil }
+func (f *fakeDB) StoreMediaFile(file *database.MediaFile) (int64, error) { return 0, nil }
+func (f *fakeDB) GetFileByID(id int64) (*database.MediaFile, error)   { return nil, nil }
+func (f *fakeDB) GetFileByPath(path string) (*database.MediaFile, error) {
+	return nil, nil
+}
+func (f *fakeDB) GetFilesInDirectory(dirPath string) ([]database.MediaFile, error) {
+	return nil, nil
+}
+func (f *fakeDB) RemoveMediaFile(path string) (bool, error) {
+	f.removed = append(f.removed, path)
+	return true, nil
+}
+func (f *fakeDB) RemoveMediaFilesUnder(prefix string) (int, error) { return 0, nil }
+func (f *fakeDB) UpdateMediaFile(file *database.MediaFile) error   { return nil }
+func (f *fakeDB) UpdateMediaFilePath(oldPath, newPath string) error {
+	f.updatedPaths = append(f.updatedPaths, [2]string{oldPath, newPath})
+	return nil
+}
+func (f *fakeDB) BatchSyncMediaFiles(batch []database.MediaFileSync) error { return nil }
+func (f *fakeDB) GetDirectoryListing(folderID int64, mediaTypeFilter string, sortKeys []database.SortKey) ([]database.MediaDirectory, []database.MediaFile, error) {
+	return nil, nil, nil
+}
+func (f *fakeDB) GetOrCreateFolder(path string) (int64, error)          { return 0, nil }
+func (f *fakeDB) GetFolderByPath(path string) (*database.Folder, error) { return nil, nil }
+func (f *fakeDB) CountChildren(folderID int64) (int, error)             { return 0, nil }
+func (f *fakeDB) GetAllPaths() ([]string, error)                        { return nil, nil }
+func (f *fakeDB) CleanupMissingFiles(existingPaths []string) (int, error) {
+	return 0, nil
+}
+func (f *fakeDB) SearchMediaFiles(criteria database.SearchCriteria, start, count int) ([]database.MediaFile, int, error) {
+	return nil, 0, nil
+}
+func (f *fakeDB) UpsertPlaylist(playlist *database.Playlist, tracks []database.PlaylistTrack) (int64, error) {
+	return 0, nil
+}
+func (f *fakeDB) GetPlaylistByPath(path string) (*database.Playlist, error) { return nil, nil }
+func (f *fakeDB) GetPlaylistTracks(playlistID int64) ([]database.PlaylistTrack, error) {
+	return nil, nil
+}
+func (f *fakeDB) ListPlaylists() ([]database.Playlist, error) { return nil, nil }
+func (f *fakeDB) ListTranscodeProfiles() ([]database.TranscodeProfile, error) {
+	return nil, nil
+}
+func (f *fakeDB) ReplaceItemTags(itemID int64, itemType string, tags map[string][]string) error {
+	return nil
+}
+func (f *fakeDB) ListTagValues(name string) ([]string, error) { return nil, nil }
+func (f *fakeDB) GetFilesByTag(name, value string) ([]database.MediaFile, error) {
+	return nil, nil
+}
+func (f *fakeDB) Close() error { return nil }
+
+func newTestWatcher(t *testing.T, db database.Manager) *Watcher {
+	t.Helper()
+	w, err := New(state.New(&config.AppConfig{}, db, nil))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return w
+}
+
+// TestHandleBatchCorrelatesRenameByInode confirms a Rename paired with a
+// Create of the same inode in the same batch is treated as a move
+// (UpdateMediaFilePath), not a delete+insert (RemoveMediaFile).
+func TestHandleBatchCorrelatesRenameByInode(t *testing.T) {
+	dir := t.TempDir()
+	oldPath := filepath.Join(dir, "old.mp4")
+	newPath := filepath.Join(dir, "new.mp4")
+	if err := os.WriteFile(oldPath, []byte("data"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	if err := os.Rename(oldPath, newPath); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	inode, ok := fileInode(newPath)
+	if !ok {
+		t.Fatal("fileInode: could not stat renamed file")
+	}
+
+	db := &fakeDB{}
+	w := newTestWatcher(t, db)
+	w.fileInodes[oldPath] = inode
+
+	changed := w.handleBatch([]fsnotify.Event{
+		{Name: oldPath, Op: fsnotify.Rename},
+		{Name: newPath, Op: fsnotify.Create},
+	})
+
+	if !changed {
+		t.Error("handleBatch reported no change for a correlated move")
+	}
+	if len(db.removed) != 0 {
+		t.Errorf("correlated move should not call RemoveMediaFile, got %v", db.removed)
+	}
+	if len(db.updatedPaths) != 1 || db.updatedPaths[0] != [2]string{oldPath, newPath} {
+		t.Errorf("updatedPaths = %v, want [[%s %s]]", db.updatedPaths, oldPath, newPath)
+	}
+	if _, stillTracked := w.fileInodes[oldPath]; stillTracked {
+		t.Error("old path's inode entry should have been moved to the new path")
+	}
+	if w.fileInodes[newPath] != inode {
+		t.Errorf("fileInodes[newPath] = %d, want %d", w.fileInodes[newPath], inode)
+	}
+}
+
+// TestHandleBatchRenameWithoutMatchingCreateIsRemoval confirms a Rename with
+// no same-batch Create sharing its inode (e.g. the file was moved outside
+// any watched directory) falls back to being treated as a removal.
+func TestHandleBatchRenameWithoutMatchingCreateIsRemoval(t *testing.T) {
+	dir := t.TempDir()
+	oldPath := filepath.Join(dir, "old.mp4")
+	if err := os.WriteFile(oldPath, []byte("data"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	inode, ok := fileInode(oldPath)
+	if !ok {
+		t.Fatal("fileInode: could not stat file")
+	}
+
+	db := &fakeDB{}
+	w := newTestWatcher(t, db)
+	w.fileInodes[oldPath] = inode
+
+	changed := w.handleBatch([]fsnotify.Event{
+		{Name: oldPath, Op: fsnotify.Rename},
+	})
+
+	if !changed {
+		t.Error("handleBatch reported no change for an unmatched rename")
+	}
+	if len(db.updatedPaths) != 0 {
+		t.Errorf("unmatched rename should not call UpdateMediaFilePath, got %v", db.updatedPaths)
+	}
+	if len(db.removed) != 1 || db.removed[0] != oldPath {
+		t.Errorf("removed = %v, want [%s]", db.removed, oldPath)
+	}
+}
@@ -0,0 +1,110 @@
+package web
+
+import (
+	"context"
+	"crypto/subtle"
+	"log/slog"
+	"net"
+	"net/http"
+
+	"vuio-go/config"
+)
+
+// authMiddleware returns the request-authentication middleware for cfg's
+// Server.AuthMode, or nil if authentication is disabled ("none"). On
+// success it stores the authenticated username in the request context
+// alongside the existing "state" value (see getUser), so per-user library
+// scoping can be layered on top later.
+func authMiddleware(cfg *config.AppConfig) func(http.Handler) http.Handler {
+	switch cfg.Server.AuthMode {
+	case config.AuthModeBasic:
+		return basicAuthMiddleware(cfg.Server.BasicAuthUsers)
+	case config.AuthModeReverseProxy:
+		return reverseProxyAuthMiddleware(cfg.Server.TrustedProxies, cfg.Server.UserHeader)
+	default:
+		return nil
+	}
+}
+
+// getUser returns the username an auth middleware stored in the request
+// context, or "" if none is set (AuthMode "none", or no middleware ran).
+func getUser(r *http.Request) string {
+	user, _ := r.Context().Value("user").(string)
+	return user
+}
+
+// basicAuthMiddleware rejects requests without valid HTTP Basic credentials
+// matching an entry in users.
+func basicAuthMiddleware(users map[string]string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			username, password, ok := r.BasicAuth()
+			want, known := users[username]
+			if !ok || password == "" || !known || subtle.ConstantTimeCompare([]byte(password), []byte(want)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Basic realm="VuIO"`)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			ctx := context.WithValue(r.Context(), "user", username)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// reverseProxyAuthMiddleware trusts userHeader only from peers in
+// trustedCIDRs, checked against the request's genuine TCP RemoteAddr (chi's
+// middleware.RealIP is deliberately not installed, since it would rewrite
+// RemoteAddr from a client-supplied header before this check ever runs),
+// rejecting everything else so a client can't set the header itself by
+// connecting directly.
+func reverseProxyAuthMiddleware(trustedCIDRs []string, userHeader string) func(http.Handler) http.Handler {
+	trusted := parseCIDRs(trustedCIDRs)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !peerTrusted(r.RemoteAddr, trusted) {
+				slog.Warn("Rejected request from untrusted proxy", "remote_addr", r.RemoteAddr)
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			username := r.Header.Get(userHeader)
+			if username == "" {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			ctx := context.WithValue(r.Context(), "user", username)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// parseCIDRs parses each CIDR, logging and skipping any that don't parse
+// rather than failing the whole server on a typo'd config entry.
+func parseCIDRs(cidrs []string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			slog.Error("Invalid trusted_proxies CIDR, ignoring", "cidr", cidr, "error", err)
+			continue
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+func peerTrusted(remoteAddr string, trusted []*net.IPNet) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr // already a bare IP, no port to strip
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, n := range trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package web
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBasicAuthMiddleware(t *testing.T) {
+	users := map[string]string{"alice": "hunter2"}
+	mw := basicAuthMiddleware(users)
+	handler := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(getUser(r)))
+	}))
+
+	cases := []struct {
+		name       string
+		username   string
+		password   string
+		setAuth    bool
+		wantStatus int
+		wantBody   string
+	}{
+		{"valid credentials", "alice", "hunter2", true, http.StatusOK, "alice"},
+		{"wrong password", "alice", "wrong", true, http.StatusUnauthorized, ""},
+		{"unknown user", "bob", "hunter2", true, http.StatusUnauthorized, ""},
+		{"empty password", "alice", "", true, http.StatusUnauthorized, ""},
+		{"no credentials", "", "", false, http.StatusUnauthorized, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if c.setAuth {
+				req.SetBasicAuth(c.username, c.password)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != c.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, c.wantStatus)
+			}
+			if rec.Code == http.StatusOK && rec.Body.String() != c.wantBody {
+				t.Errorf("body = %q, want %q", rec.Body.String(), c.wantBody)
+			}
+			if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("expected WWW-Authenticate header on 401")
+			}
+		})
+	}
+}
+
+func TestReverseProxyAuthMiddleware(t *testing.T) {
+	mw := reverseProxyAuthMiddleware([]string{"10.0.0.0/8"}, "X-Remote-User")
+	handler := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(getUser(r)))
+	}))
+
+	cases := []struct {
+		name       string
+		remoteAddr string
+		header     string
+		wantStatus int
+	}{
+		{"trusted proxy with user", "10.1.2.3:5555", "carol", http.StatusOK},
+		{"trusted proxy no user header", "10.1.2.3:5555", "", http.StatusUnauthorized},
+		{"untrusted proxy", "203.0.113.9:5555", "carol", http.StatusForbidden},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = c.remoteAddr
+			if c.header != "" {
+				req.Header.Set("X-Remote-User", c.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != c.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, c.wantStatus)
+			}
+			if c.wantStatus == http.StatusOK && rec.Body.String() != c.header {
+				t.Errorf("body = %q, want %q", rec.Body.String(), c.header)
+			}
+		})
+	}
+}
+
+func TestPeerTrusted(t *testing.T) {
+	_, cidr, _ := net.ParseCIDR("192.168.1.0/24")
+	trusted := []*net.IPNet{cidr}
+
+	cases := []struct {
+		remoteAddr string
+		want       bool
+	}{
+		{"192.168.1.42:1234", true},
+		{"192.168.2.1:1234", false},
+		{"192.168.1.42", true}, // no port to strip
+		{"not-an-ip:1234", false},
+	}
+	for _, c := range cases {
+		if got := peerTrusted(c.remoteAddr, trusted); got != c.want {
+			t.Errorf("peerTrusted(%q) = %v, want %v", c.remoteAddr, got, c.want)
+		}
+	}
+}
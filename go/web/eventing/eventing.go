@@ -0,0 +1,316 @@
+// Package eventing implements GENA (General Event Notification Architecture)
+// subscriptions for the ContentDirectory service: SUBSCRIBE/UNSUBSCRIBE
+// bookkeeping and NOTIFY delivery when the library's UpdateIDs change.
+package eventing
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultTimeout is used when a SUBSCRIBE request omits the TIMEOUT header.
+const DefaultTimeout = 30 * time.Minute
+
+// subscription is one GENA subscriber to ContentDirectory eventing.
+type subscription struct {
+	sid      string
+	callback string
+	seq      uint32
+	expires  time.Time
+}
+
+// Manager tracks GENA subscriptions for the ContentDirectory service and
+// delivers NOTIFY requests to each subscriber when content changes.
+type Manager struct {
+	client *http.Client
+
+	mu   sync.Mutex
+	subs map[string]*subscription
+}
+
+// NewManager creates a Manager and starts its periodic subscription sweeper.
+func NewManager() *Manager {
+	m := &Manager{
+		client: newNotifyClient(net.DefaultResolver.LookupIP),
+		subs:   make(map[string]*subscription),
+	}
+	go m.reapLoop()
+	return m
+}
+
+// newNotifyClient builds the HTTP client used for GENA NOTIFY delivery. Its
+// transport calls resolve and re-checks the callback host against
+// callbackIPAllowed on every dial, not just once at SUBSCRIBE time, so a
+// subscriber can't pass validation with a public address and then
+// DNS-rebind its callback host to a loopback/link-local target before a
+// later NOTIFY fires. Keep-alives are disabled so every NOTIFY actually
+// opens a fresh connection through that check instead of reusing a pooled
+// connection from an earlier, legitimately-validated dial. It also refuses
+// to follow redirects, since a subscriber's first response could otherwise
+// 3xx the client anywhere with no further checks at all. resolve is
+// net.DefaultResolver.LookupIP in production; tests substitute a stub to
+// exercise rebinding without a real DNS change between calls.
+func newNotifyClient(resolve func(ctx context.Context, network, host string) ([]net.IP, error)) *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	transport := &http.Transport{
+		DisableKeepAlives: true,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			host, port, err := net.SplitHostPort(addr)
+			if err != nil {
+				return nil, err
+			}
+			ips, err := resolve(ctx, "ip", host)
+			if err != nil {
+				return nil, err
+			}
+			var lastErr error
+			for _, ip := range ips {
+				if !callbackIPAllowed(ip) {
+					lastErr = fmt.Errorf("callback host %q resolves to a disallowed address %s", host, ip)
+					continue
+				}
+				conn, dialErr := dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+				if dialErr == nil {
+					return conn, nil
+				}
+				lastErr = dialErr
+			}
+			if lastErr == nil {
+				lastErr = fmt.Errorf("no usable address for callback host %q", host)
+			}
+			return nil, lastErr
+		},
+	}
+	return &http.Client{
+		Timeout:   5 * time.Second,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Subscribe registers callback (the URL inside a SUBSCRIBE request's
+// CALLBACK header) with a new SID, sends it an initial NOTIFY carrying the
+// current state, and returns the SID and the timeout in effect.
+func (m *Manager) Subscribe(callback string, timeout time.Duration, systemUpdateID uint32, containerUpdateIDs string) (sid string, effectiveTimeout time.Duration, err error) {
+	callback, err = parseCallback(callback)
+	if err != nil {
+		return "", 0, err
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	sub := &subscription{
+		sid:      "uuid:" + uuid.New().String(),
+		callback: callback,
+		expires:  time.Now().Add(timeout),
+	}
+
+	m.mu.Lock()
+	m.subs[sub.sid] = sub
+	m.mu.Unlock()
+
+	go m.deliver(sub, systemUpdateID, containerUpdateIDs)
+
+	return sub.sid, timeout, nil
+}
+
+// Renew extends an existing subscription's expiry, as GENA's re-SUBSCRIBE
+// (SUBSCRIBE with an existing SID instead of a CALLBACK) requests.
+func (m *Manager) Renew(sid string, timeout time.Duration) (time.Duration, error) {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sub, ok := m.subs[sid]
+	if !ok {
+		return 0, fmt.Errorf("unknown subscription SID %q", sid)
+	}
+	sub.expires = time.Now().Add(timeout)
+	return timeout, nil
+}
+
+// Unsubscribe drops sid, as requested by an UNSUBSCRIBE request.
+func (m *Manager) Unsubscribe(sid string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.subs[sid]; !ok {
+		return fmt.Errorf("unknown subscription SID %q", sid)
+	}
+	delete(m.subs, sid)
+	return nil
+}
+
+// Notify sends a NOTIFY to every active subscriber reporting the new
+// SystemUpdateID and, if any containers changed, ContainerUpdateIDs.
+func (m *Manager) Notify(systemUpdateID uint32, containerUpdateIDs string) {
+	m.mu.Lock()
+	subs := make([]*subscription, 0, len(m.subs))
+	for _, sub := range m.subs {
+		subs = append(subs, sub)
+	}
+	m.mu.Unlock()
+
+	for _, sub := range subs {
+		go m.deliver(sub, systemUpdateID, containerUpdateIDs)
+	}
+}
+
+// deliver POSTs one NOTIFY to sub's callback URL, incrementing its sequence
+// counter. Delivery failures are logged, not retried; a subscriber that
+// stays unreachable is eventually dropped by reapLoop once it expires.
+func (m *Manager) deliver(sub *subscription, systemUpdateID uint32, containerUpdateIDs string) {
+	m.mu.Lock()
+	seq := sub.seq
+	sub.seq++
+	m.mu.Unlock()
+
+	body := propertySetXML(systemUpdateID, containerUpdateIDs)
+	req, err := http.NewRequest(http.MethodPost, sub.callback, bytes.NewReader([]byte(body)))
+	if err != nil {
+		slog.Error("Failed to build GENA NOTIFY request", "sid", sub.sid, "callback", sub.callback, "error", err)
+		return
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("NT", "upnp:event")
+	req.Header.Set("NTS", "upnp:propchange")
+	req.Header.Set("SID", sub.sid)
+	req.Header.Set("SEQ", strconv.FormatUint(uint64(seq), 10))
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		slog.Warn("GENA NOTIFY delivery failed", "sid", sub.sid, "callback", sub.callback, "error", err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		slog.Warn("GENA NOTIFY rejected by subscriber", "sid", sub.sid, "callback", sub.callback, "status", resp.StatusCode)
+	}
+}
+
+// reapLoop periodically drops subscriptions past their expiry, for
+// subscribers that disappeared without sending UNSUBSCRIBE.
+func (m *Manager) reapLoop() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		m.reapExpired()
+	}
+}
+
+func (m *Manager) reapExpired() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for sid, sub := range m.subs {
+		if now.After(sub.expires) {
+			slog.Info("GENA subscription expired", "sid", sid, "callback", sub.callback)
+			delete(m.subs, sid)
+		}
+	}
+}
+
+// propertySetXML renders the e:propertyset body GENA NOTIFY requests carry,
+// one e:property per changed state variable.
+func propertySetXML(systemUpdateID uint32, containerUpdateIDs string) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>`)
+	b.WriteString(`<e:propertyset xmlns:e="urn:schemas-upnp-org:event-1-0">`)
+	fmt.Fprintf(&b, `<e:property><SystemUpdateID>%d</SystemUpdateID></e:property>`, systemUpdateID)
+	if containerUpdateIDs != "" {
+		fmt.Fprintf(&b, `<e:property><ContainerUpdateIDs>%s</ContainerUpdateIDs></e:property>`, containerUpdateIDs)
+	}
+	b.WriteString(`</e:propertyset>`)
+	return b.String()
+}
+
+// parseCallback extracts the URL from a GENA CALLBACK header, which wraps
+// it in angle brackets (and may list several; VuIO only needs the first).
+func parseCallback(header string) (string, error) {
+	start := strings.Index(header, "<")
+	end := strings.Index(header, ">")
+	if start == -1 || end == -1 || end < start {
+		return "", fmt.Errorf("malformed CALLBACK header %q", header)
+	}
+	callbackURL := header[start+1 : end]
+	if callbackURL == "" {
+		return "", fmt.Errorf("empty CALLBACK URL")
+	}
+	if err := validateCallbackURL(callbackURL); err != nil {
+		return "", err
+	}
+	return callbackURL, nil
+}
+
+// validateCallbackURL rejects CALLBACK targets that would turn NOTIFY
+// delivery into a server-side request forgery primitive: non-HTTP(S)
+// schemes, and loopback/link-local hosts, which would reach a service on
+// the server's own machine or a cloud metadata endpoint rather than an
+// actual UPnP control point. Ordinary private-network addresses are left
+// alone, since that's where every real DLNA renderer lives.
+//
+// This is a fail-fast check at SUBSCRIBE time only; the resolution here is
+// not what protects delivery, since a rebind after this check would sail
+// straight through it. newNotifyClient's dialer re-checks callbackIPAllowed
+// against the address actually being connected to on every NOTIFY.
+func validateCallbackURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid CALLBACK URL %q: %w", raw, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported CALLBACK scheme %q", u.Scheme)
+	}
+	host := u.Hostname()
+	if host == "" {
+		return fmt.Errorf("missing CALLBACK host in %q", raw)
+	}
+
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return fmt.Errorf("failed to resolve CALLBACK host %q: %w", host, err)
+	}
+	for _, ip := range ips {
+		if !callbackIPAllowed(ip) {
+			return fmt.Errorf("CALLBACK host %q resolves to a disallowed address %s", host, ip)
+		}
+	}
+	return nil
+}
+
+// callbackIPAllowed reports whether ip is a legitimate NOTIFY destination:
+// not loopback, link-local, or unspecified. It's the single source of truth
+// shared by validateCallbackURL's SUBSCRIBE-time check and
+// newNotifyClient's per-dial check.
+func callbackIPAllowed(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() && !ip.IsUnspecified()
+}
+
+// ParseTimeout parses a GENA TIMEOUT header value (e.g. "Second-1800"),
+// returning DefaultTimeout for "Second-infinite" or an unparsable value.
+func ParseTimeout(header string) time.Duration {
+	const prefix = "Second-"
+	if !strings.HasPrefix(header, prefix) {
+		return DefaultTimeout
+	}
+	seconds, err := strconv.Atoi(strings.TrimPrefix(header, prefix))
+	if err != nil || seconds <= 0 {
+		return DefaultTimeout
+	}
+	return time.Duration(seconds) * time.Second
+}
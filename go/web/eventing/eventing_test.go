@@ -0,0 +1,170 @@
+package eventing
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCallbackIPAllowed(t *testing.T) {
+	cases := []struct {
+		ip      string
+		allowed bool
+	}{
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"169.254.1.1", false},  // link-local unicast
+		{"224.0.0.251", false},  // link-local multicast
+		{"0.0.0.0", false},      // unspecified
+		{"192.168.1.50", true},  // ordinary private-network renderer
+		{"93.184.216.34", true}, // ordinary public address
+	}
+	for _, c := range cases {
+		if got := callbackIPAllowed(net.ParseIP(c.ip)); got != c.allowed {
+			t.Errorf("callbackIPAllowed(%s) = %v, want %v", c.ip, got, c.allowed)
+		}
+	}
+}
+
+func TestValidateCallbackURL(t *testing.T) {
+	cases := []struct {
+		name    string
+		url     string
+		wantErr bool
+	}{
+		{"loopback rejected", "http://127.0.0.1:8080/notify", true},
+		{"bad scheme rejected", "ftp://192.168.1.1/notify", true},
+		{"malformed URL rejected", "http://[::1", true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validateCallbackURL(c.url)
+			if (err != nil) != c.wantErr {
+				t.Errorf("validateCallbackURL(%q) error = %v, wantErr %v", c.url, err, c.wantErr)
+			}
+		})
+	}
+}
+
+// nonLoopbackIP returns a non-loopback IPv4 address this machine has
+// configured, or skips the test if none is available (some sandboxes only
+// bring up loopback).
+func nonLoopbackIP(t *testing.T) net.IP {
+	t.Helper()
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		t.Skipf("cannot list interface addresses: %v", err)
+	}
+	for _, a := range addrs {
+		ipnet, ok := a.(*net.IPNet)
+		if ok && !ipnet.IP.IsLoopback() && ipnet.IP.To4() != nil {
+			return ipnet.IP
+		}
+	}
+	t.Skip("no non-loopback IPv4 interface available")
+	return nil
+}
+
+// listenOn starts an HTTP server bound to ip and returns its address.
+func listenOn(t *testing.T, ip net.IP, handler http.HandlerFunc) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", net.JoinHostPort(ip.String(), "0"))
+	if err != nil {
+		t.Skipf("cannot listen on %s: %v", ip, err)
+	}
+	srv := &http.Server{Handler: handler}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+	addr := ln.Addr().(*net.TCPAddr)
+	return net.JoinHostPort(ip.String(), strconv.Itoa(addr.Port))
+}
+
+// TestDeliverBlocksDNSRebind exercises newNotifyClient's dial-time
+// re-validation: the first NOTIFY resolves the callback host to a real,
+// reachable, non-loopback address, but a later NOTIFY observes the same
+// host having rebound to loopback, simulating a subscriber that passed
+// SUBSCRIBE-time validation and then DNS-rebound its callback. The rebound
+// dial must be refused rather than silently connecting to the address it
+// now resolves to.
+func TestDeliverBlocksDNSRebind(t *testing.T) {
+	ip := nonLoopbackIP(t)
+
+	var hits atomic.Int32
+	addr := listenOn(t, ip, func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusOK)
+	})
+	_, port, _ := net.SplitHostPort(addr)
+
+	var rebound atomic.Bool
+	resolve := func(ctx context.Context, network, host string) ([]net.IP, error) {
+		if rebound.Load() {
+			return []net.IP{net.ParseIP("127.0.0.1")}, nil
+		}
+		return []net.IP{ip}, nil
+	}
+
+	m := &Manager{
+		client: newNotifyClient(resolve),
+		subs:   make(map[string]*subscription),
+	}
+	sub := &subscription{
+		sid:      "uuid:test",
+		callback: "http://rebind.example:" + port + "/notify",
+		expires:  time.Now().Add(time.Hour),
+	}
+
+	m.deliver(sub, 1, "")
+	if hits.Load() != 1 {
+		t.Fatalf("expected first deliver to reach the server once, got %d hits", hits.Load())
+	}
+
+	rebound.Store(true)
+	m.deliver(sub, 2, "")
+	if hits.Load() != 1 {
+		t.Fatalf("deliver dialed the rebound loopback address instead of refusing it, hits=%d", hits.Load())
+	}
+}
+
+// TestDeliverBlocksRedirect confirms deliver's client does not follow a
+// redirect response from the subscriber's callback endpoint, since a
+// subscriber's first response could otherwise 3xx the client anywhere with
+// no further SSRF checks at all.
+func TestDeliverBlocksRedirect(t *testing.T) {
+	ip := nonLoopbackIP(t)
+
+	var redirectTargetHit atomic.Bool
+	internalAddr := listenOn(t, ip, func(w http.ResponseWriter, r *http.Request) {
+		redirectTargetHit.Store(true)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	redirectingAddr := listenOn(t, ip, func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://"+internalAddr+"/", http.StatusFound)
+	})
+	_, port, _ := net.SplitHostPort(redirectingAddr)
+
+	resolve := func(ctx context.Context, network, host string) ([]net.IP, error) {
+		return []net.IP{ip}, nil
+	}
+
+	m := &Manager{
+		client: newNotifyClient(resolve),
+		subs:   make(map[string]*subscription),
+	}
+	sub := &subscription{
+		sid:      "uuid:test2",
+		callback: "http://redirecting.example:" + port + "/notify",
+		expires:  time.Now().Add(time.Hour),
+	}
+
+	m.deliver(sub, 1, "")
+
+	if redirectTargetHit.Load() {
+		t.Fatal("deliver followed the redirect instead of treating it as the final response")
+	}
+}
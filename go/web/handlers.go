@@ -1,17 +1,19 @@
 package web
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"os"
-	"path/filepath" // Added for path manipulation
 	"strconv"
 	"strings"
 
 	"vuio-go/database"
 	"vuio-go/state"
+	"vuio-go/transcode"
+	"vuio-go/web/eventing"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -41,6 +43,14 @@ func soapHandler(next func(w http.ResponseWriter, r *http.Request) (string, erro
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		resultXML, err := next(w, r)
 		if err != nil {
+			var fault *soapFault
+			if errors.As(err, &fault) {
+				slog.Warn("SOAP control fault", "code", fault.code, "description", fault.description)
+				w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = w.Write([]byte(generateSOAPFaultXML(fault.code, fault.description)))
+				return
+			}
 			slog.Error("SOAP handler error", "error", err)
 			http.Error(w, "UPnP Error", http.StatusInternalServerError)
 			return
@@ -51,6 +61,23 @@ func soapHandler(next func(w http.ResponseWriter, r *http.Request) (string, erro
 	})
 }
 
+// soapFault is an error carrying a UPnP control error code, rendered by
+// soapHandler as a proper SOAP Fault body instead of a generic HTTP error.
+type soapFault struct {
+	code        int
+	description string
+}
+
+func (f *soapFault) Error() string {
+	return fmt.Sprintf("UPnP error %d: %s", f.code, f.description)
+}
+
+// newInvalidArgsFault builds the 708 InvalidArgs fault ContentDirectory
+// clients expect for a malformed or unsupported SearchCriteria.
+func newInvalidArgsFault(description string) error {
+	return &soapFault{code: 708, description: description}
+}
+
 func contentDirectoryControlHandler(w http.ResponseWriter, r *http.Request) (string, error) {
 	state := getState(r)
 	body, err := io.ReadAll(r.Body)
@@ -61,11 +88,23 @@ func contentDirectoryControlHandler(w http.ResponseWriter, r *http.Request) (str
 	bodyStr := string(body)
 	if action, ok := getSOAPAction(bodyStr, "Browse"); ok {
 		params := parseBrowseParams(action)
-		slog.Info("Browse request", "ObjectID", params.ObjectID, "StartIndex", params.StartingIndex, "Count", params.RequestedCount)
+		slog.Info("Browse request", "ObjectID", params.ObjectID, "StartIndex", params.StartingIndex, "Count", params.RequestedCount, "SortCriteria", params.SortCriteria)
+
+		sortKeys, err := database.ParseSortCriteria(params.SortCriteria)
+		if err != nil {
+			return "", newInvalidArgsFault(err.Error())
+		}
+
+		if params.ObjectID == "playlists" || strings.HasPrefix(params.ObjectID, "playlists/") {
+			return browsePlaylists(params.ObjectID, state)
+		}
+
+		if isTagBrowseObjectID(params.ObjectID) {
+			return browseByTag(params.ObjectID, state)
+		}
 
 		var subdirs []database.MediaDirectory
 		var files []database.MediaFile
-		var err error
 
 		if params.ObjectID == "0" {
 			// Root object ID. The response will contain the virtual directories.
@@ -73,11 +112,8 @@ func contentDirectoryControlHandler(w http.ResponseWriter, r *http.Request) (str
 			subdirs = []database.MediaDirectory{}
 			files = []database.MediaFile{}
 		} else {
-			// Determine browse path and filter from object ID.
-			// This is simplified and assumes a single media root. A more robust implementation
-			// would map 'video', 'audio', 'image' roots to different configured directories.
-			mediaRoot := state.Config.GetPrimaryMediaDir()
-			var browsePath string
+			// Determine the media type and remaining path from the object ID,
+			// then route to whichever configured MediaRoot(s) serve that type.
 			var mediaTypeFilter string
 			var subPath string
 
@@ -95,11 +131,46 @@ func contentDirectoryControlHandler(w http.ResponseWriter, r *http.Request) (str
 				return "", fmt.Errorf("invalid or unhandled ObjectID: %s", params.ObjectID)
 			}
 
-			// Remove any leading slash from the subPath to ensure filepath.Join works correctly
 			subPath = strings.TrimPrefix(subPath, "/")
-			browsePath = filepath.Join(mediaRoot, subPath)
+			roots := state.Config.GetMediaRootsForType(mediaTypeFilter)
+
+			switch {
+			case len(roots) == 0:
+				// No root configured for this media type.
+			case len(roots) == 1:
+				var folderID int64
+				folderID, err = resolveMediaRootFolderID(state, roots[0], subPath)
+				if err != nil {
+					return "", err
+				}
+				subdirs, files, err = state.DB.GetDirectoryListing(folderID, mediaTypeFilter, sortKeys)
+			case subPath == "":
+				// Multiple roots serve this media type: present them as sibling
+				// containers rather than guessing which one to descend into.
+				for _, root := range roots {
+					subdirs = append(subdirs, database.MediaDirectory{Name: root.Name})
+				}
+			default:
+				segments := strings.SplitN(subPath, "/", 2)
+				root, ok := findMediaRootByName(roots, segments[0])
+				if !ok {
+					return "", fmt.Errorf("unknown media root in ObjectID: %s", params.ObjectID)
+				}
+				rest := ""
+				if len(segments) > 1 {
+					rest = segments[1]
+				}
+				var folderID int64
+				folderID, err = resolveMediaRootFolderID(state, root, rest)
+				if err != nil {
+					return "", err
+				}
+				subdirs, files, err = state.DB.GetDirectoryListing(folderID, mediaTypeFilter, sortKeys)
+			}
 
-			subdirs, files, err = state.DB.GetDirectoryListing(browsePath, mediaTypeFilter)
+			if err == nil && mediaTypeFilter == "audio" && subPath == "" {
+				subdirs = append(subdirs, tagBrowseRootDirs...)
+			}
 		}
 
 		if err != nil {
@@ -107,15 +178,62 @@ func contentDirectoryControlHandler(w http.ResponseWriter, r *http.Request) (str
 		}
 
 		totalMatches := len(subdirs) + len(files)
-		// For the root object, there are always 3 virtual containers.
+		// For the root object, there are always 4 virtual containers
+		// (video, audio, image, playlists).
 		if params.ObjectID == "0" {
-			totalMatches = 3
+			totalMatches = 4
 		}
 
-		response := generateBrowseResponse(params.ObjectID, subdirs, files, totalMatches, state)
+		response := generateBrowseResponse(params.ObjectID, subdirs, files, totalMatches, state, r.UserAgent())
 		return response, nil
 	}
 
+	if action, ok := getSOAPAction(bodyStr, "Search"); ok {
+		params := parseSearchParams(action)
+		slog.Info("Search request", "ContainerID", params.ContainerID, "SearchCriteria", params.SearchCriteria)
+
+		criteria, err := ParseSearchCriteria(params.SearchCriteria)
+		if err != nil {
+			return "", newInvalidArgsFault(err.Error())
+		}
+
+		count := params.RequestedCount
+		if count <= 0 {
+			count = 1000
+		}
+
+		files, total, err := state.DB.SearchMediaFiles(criteria, params.StartingIndex, count)
+		if err != nil {
+			if errors.Is(err, database.ErrInvalidSearchCriteria) {
+				return "", newInvalidArgsFault(err.Error())
+			}
+			return "", err
+		}
+
+		response := generateSearchResponse(params.ContainerID, files, total, state, r.UserAgent())
+		return response, nil
+	}
+
+	if _, ok := getSOAPAction(bodyStr, "GetSearchCapabilities"); ok {
+		return wrapSimpleResponse("GetSearchCapabilities", "SearchCaps", strings.Join(database.SearchableProperties, ",")), nil
+	}
+
+	if _, ok := getSOAPAction(bodyStr, "GetSortCapabilities"); ok {
+		return wrapSimpleResponse("GetSortCapabilities", "SortCaps", strings.Join(database.SortableProperties, ",")), nil
+	}
+
+	if _, ok := getSOAPAction(bodyStr, "GetSortExtensionCapabilities"); ok {
+		return wrapSimpleResponse("GetSortExtensionCapabilities", "SortExtensionCaps", ""), nil
+	}
+
+	if _, ok := getSOAPAction(bodyStr, "GetFeatureList"); ok {
+		return wrapSimpleResponse("GetFeatureList", "FeatureList", generateFeatureListXML()), nil
+	}
+
+	if _, ok := getSOAPAction(bodyStr, "GetSystemUpdateID"); ok {
+		return wrapSimpleResponse("GetSystemUpdateID", "Id", strconv.FormatUint(uint64(state.GetUpdateID()), 10)), nil
+	}
+
 	return "", fmt.Errorf("unsupported SOAP action")
 }
 
@@ -140,6 +258,13 @@ func serveMediaHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if state.GetConfig().Transcode.Enabled {
+		if profile := transcode.SelectProfile(state.TranscodeProfiles, r.UserAgent(), fileInfo.MimeType); profile != nil {
+			serveTranscodedMedia(w, r, fileInfo, *profile)
+			return
+		}
+	}
+
 	file, err := os.Open(fileInfo.Path)
 	if err != nil {
 		slog.Error("Failed to open media file", "path", fileInfo.Path, "error", err)
@@ -152,10 +277,117 @@ func serveMediaHandler(w http.ResponseWriter, r *http.Request) {
 	http.ServeContent(w, r, fileInfo.Filename, fileInfo.Modified, file)
 }
 
-func eventSubscribeHandler(w http.ResponseWriter, r *http.Request) {
-	// This is a stub implementation. A real one would manage subscriptions.
-	slog.Info("Received event subscription request", "method", r.Method, "callback", r.Header.Get("CALLBACK"))
-	w.Header().Set("SID", "uuid:fake-subscription-id")
-	w.Header().Set("TIMEOUT", "Second-1800")
+// serveTranscodedMedia pipes fileInfo through ffmpeg per profile and streams
+// the result directly to the client, since the transcoded output has no
+// fixed length to serve with http.ServeContent. When a caching Transcoder is
+// configured, concurrent requests for the same file/profile/bitrate share
+// one ffmpeg process instead of each spawning their own.
+func serveTranscodedMedia(w http.ResponseWriter, r *http.Request, fileInfo *database.MediaFile, profile database.TranscodeProfile) {
+	state := getState(r)
+	seekSeconds := estimateSeekSeconds(r.Header.Get("Range"), fileInfo)
+
+	var hwaccelArgs []string
+	if strings.HasPrefix(fileInfo.MimeType, "video/") {
+		cfg := state.GetConfig()
+		accel := transcode.SelectAccel(cfg.Transcode.PreferredAccel, state.Capabilities)
+		hwaccelArgs = transcode.HWAccelArgs(accel, cfg.Transcode.VaapiDevice)
+	}
+
+	w.Header().Set("Content-Type", profile.TargetMime)
+	w.Header().Set("Accept-Ranges", "none")
 	w.WriteHeader(http.StatusOK)
+
+	if state.Transcoder == nil {
+		if err := transcode.Stream(r.Context(), w, fileInfo.Path, profile, seekSeconds, hwaccelArgs); err != nil {
+			slog.Warn("Transcoding stream ended", "path", fileInfo.Path, "error", err)
+		}
+		return
+	}
+
+	reader, err := state.Transcoder.Transcode(r.Context(), fileInfo.Path, profile, seekSeconds, hwaccelArgs)
+	if err != nil {
+		slog.Warn("Failed to start transcode", "path", fileInfo.Path, "error", err)
+		return
+	}
+	defer reader.Close()
+
+	if _, err := io.Copy(w, reader); err != nil {
+		slog.Warn("Transcoding stream ended", "path", fileInfo.Path, "error", err)
+	}
+}
+
+// estimateSeekSeconds maps a byte-range request onto an approximate time
+// offset, since the transcoded stream can no longer be seeked by byte
+// position. It returns 0 when the file has no known duration or the Range
+// header can't be parsed.
+func estimateSeekSeconds(rangeHeader string, fileInfo *database.MediaFile) float64 {
+	if rangeHeader == "" || !fileInfo.Duration.Valid || fileInfo.Size <= 0 {
+		return 0
+	}
+
+	const prefix = "bytes="
+	if !strings.HasPrefix(rangeHeader, prefix) {
+		return 0
+	}
+
+	offsetStr := strings.SplitN(strings.TrimPrefix(rangeHeader, prefix), "-", 2)[0]
+	offset, err := strconv.ParseInt(offsetStr, 10, 64)
+	if err != nil || offset <= 0 {
+		return 0
+	}
+
+	fraction := float64(offset) / float64(fileInfo.Size)
+	durationSeconds := float64(fileInfo.Duration.Int64) / 1000.0
+	return fraction * durationSeconds
+}
+
+// eventSubscribeHandler implements GENA SUBSCRIBE/UNSUBSCRIBE for the
+// ContentDirectory event URL, delegating the subscription bookkeeping and
+// NOTIFY delivery to state.Events.
+func eventSubscribeHandler(w http.ResponseWriter, r *http.Request) {
+	state := getState(r)
+
+	switch r.Method {
+	case "SUBSCRIBE":
+		if sid := r.Header.Get("SID"); sid != "" {
+			timeout, err := state.Events.Renew(sid, eventing.ParseTimeout(r.Header.Get("TIMEOUT")))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusPreconditionFailed)
+				return
+			}
+			w.Header().Set("SID", sid)
+			w.Header().Set("TIMEOUT", fmt.Sprintf("Second-%d", int(timeout.Seconds())))
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		callback := r.Header.Get("CALLBACK")
+		if callback == "" {
+			http.Error(w, "missing CALLBACK header", http.StatusPreconditionFailed)
+			return
+		}
+		sid, timeout, err := state.Events.Subscribe(callback, eventing.ParseTimeout(r.Header.Get("TIMEOUT")), state.GetUpdateID(), state.ContainerUpdateIDs())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusPreconditionFailed)
+			return
+		}
+		w.Header().Set("SID", sid)
+		w.Header().Set("TIMEOUT", fmt.Sprintf("Second-%d", int(timeout.Seconds())))
+		w.WriteHeader(http.StatusOK)
+
+	case "UNSUBSCRIBE":
+		sid := r.Header.Get("SID")
+		if sid == "" {
+			http.Error(w, "missing SID header", http.StatusPreconditionFailed)
+			return
+		}
+		if err := state.Events.Unsubscribe(sid); err != nil {
+			http.Error(w, err.Error(), http.StatusPreconditionFailed)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
 }
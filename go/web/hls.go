@@ -0,0 +1,108 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"vuio-go/database"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// hlsMediaFile resolves the {id} URL param to a MediaFile, writing the
+// appropriate error response and returning ok=false if it can't be found.
+func hlsMediaFile(w http.ResponseWriter, r *http.Request) (*database.MediaFile, bool) {
+	state := getState(r)
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	mf, err := state.DB.GetFileByID(id)
+	if err != nil {
+		slog.Error("Error getting file from DB", "id", id, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return nil, false
+	}
+	if mf == nil {
+		http.NotFound(w, r)
+		return nil, false
+	}
+	return mf, true
+}
+
+func hlsMasterPlaylistHandler(w http.ResponseWriter, r *http.Request) {
+	state := getState(r)
+	mf, ok := hlsMediaFile(w, r)
+	if !ok {
+		return
+	}
+
+	sess, err := state.HLS.GetOrCreateSession(mf)
+	if err != nil {
+		slog.Error("Failed to create HLS session", "id", mf.ID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	_, _ = w.Write([]byte(sess.MasterPlaylist()))
+}
+
+func hlsVariantPlaylistHandler(w http.ResponseWriter, r *http.Request) {
+	serveHlsFile(w, r, "index.m3u8")
+}
+
+func hlsSegmentHandler(w http.ResponseWriter, r *http.Request) {
+	serveHlsFile(w, r, fmt.Sprintf("segment_%s.ts", chi.URLParam(r, "n")))
+}
+
+// serveHlsFile resolves the session and rung named in the URL, ensures the
+// rung's ffmpeg segmenter has been started, then serves fileName out of its
+// output directory.
+func serveHlsFile(w http.ResponseWriter, r *http.Request, fileName string) {
+	state := getState(r)
+	mf, ok := hlsMediaFile(w, r)
+	if !ok {
+		return
+	}
+
+	sess, err := state.HLS.GetOrCreateSession(mf)
+	if err != nil {
+		slog.Error("Failed to create HLS session", "id", mf.ID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	rungName := chi.URLParam(r, "rung")
+	rung, ok := sess.Rung(rungName)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	// The segmenter outlives this request; it is stopped on idle eviction.
+	if err := sess.EnsureRungStarted(context.WithoutCancel(r.Context()), mf.Path, rung); err != nil {
+		slog.Error("Failed to start HLS segmenter", "id", mf.ID, "rung", rungName, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	path, err := sess.FilePath(rungName, fileName)
+	if err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	if strings.HasSuffix(fileName, ".m3u8") {
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	} else {
+		w.Header().Set("Content-Type", "video/mp2t")
+	}
+	http.ServeFile(w, r, path)
+}
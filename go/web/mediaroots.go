@@ -0,0 +1,47 @@
+package web
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"vuio-go/config"
+	"vuio-go/state"
+)
+
+// findMediaRootByName looks up a MediaRoot by its configured name among the
+// roots serving a given media type.
+func findMediaRootByName(roots []config.MediaRoot, name string) (config.MediaRoot, bool) {
+	for _, root := range roots {
+		if root.Name == name {
+			return root, true
+		}
+	}
+	return config.MediaRoot{}, false
+}
+
+// resolveMediaRootFolderID resolves the folders.id to browse within root,
+// given the ObjectID path remaining after the media type (and, for
+// multi-root types, the root name) have been stripped off. An empty
+// subPath means the root of the MediaRoot itself.
+func resolveMediaRootFolderID(state *state.AppState, root config.MediaRoot, subPath string) (int64, error) {
+	if subPath == "" {
+		folder, err := state.DB.GetFolderByPath(root.Path)
+		if err != nil {
+			return 0, err
+		}
+		if folder == nil {
+			return 0, nil
+		}
+		return folder.ID, nil
+	}
+
+	// Every non-root ObjectID ends in the folders.id of the folder being
+	// browsed; earlier segments are just breadcrumbs.
+	segments := strings.Split(subPath, "/")
+	folderID, err := strconv.ParseInt(segments[len(segments)-1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid folder ObjectID segment: %s", subPath)
+	}
+	return folderID, nil
+}
@@ -0,0 +1,99 @@
+package web
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"vuio-go/database"
+	"vuio-go/platform"
+	"vuio-go/state"
+)
+
+// browsePlaylists handles Browse requests for the virtual "playlists" root
+// container and its children, rendering each playlist as an
+// object.container.playlistContainer whose items point at existing
+// /media/{id} URLs.
+func browsePlaylists(objectID string, state *state.AppState) (string, error) {
+	if objectID == "playlists" {
+		playlists, err := state.DB.ListPlaylists()
+		if err != nil {
+			return "", err
+		}
+		return generatePlaylistsResponse(playlists, state), nil
+	}
+
+	idStr := strings.TrimPrefix(objectID, "playlists/")
+	playlistID, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid playlist ObjectID: %s", objectID)
+	}
+
+	tracks, err := state.DB.GetPlaylistTracks(playlistID)
+	if err != nil {
+		return "", err
+	}
+	return generatePlaylistTracksResponse(objectID, tracks, state), nil
+}
+
+func generatePlaylistsResponse(playlists []database.Playlist, state *state.AppState) string {
+	var didl strings.Builder
+	didl.WriteString(`<DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:upnp="urn:schemas-upnp-org:metadata-1-0/upnp/">`)
+
+	for _, pl := range playlists {
+		containerID := fmt.Sprintf("playlists/%d", pl.ID)
+		didl.WriteString(fmt.Sprintf(`<container id="%s" parentID="playlists" restricted="1"><dc:title>%s</dc:title><upnp:class>object.container.playlistContainer</upnp:class></container>`,
+			xmlEscape(containerID), xmlEscape(pl.Name)))
+	}
+	didl.WriteString(`</DIDL-Lite>`)
+
+	return wrapBrowseResponse(didl.String(), len(playlists), len(playlists), state)
+}
+
+func generatePlaylistTracksResponse(objectID string, tracks []database.PlaylistTrack, state *state.AppState) string {
+	var didl strings.Builder
+	didl.WriteString(`<DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:upnp="urn:schemas-upnp-org:metadata-1-0/upnp/">`)
+
+	serverIP, err := platform.GetPrimaryIP()
+	if err != nil {
+		slog.Error("Could not get primary IP for playlist browse response", "error", err)
+		serverIP = "127.0.0.1"
+	}
+	port := state.GetConfig().Server.Port
+
+	numberReturned := 0
+	for _, track := range tracks {
+		if !track.MediaFileID.Valid {
+			continue
+		}
+		file, err := state.DB.GetFileByID(track.MediaFileID.Int64)
+		if err != nil || file == nil {
+			continue
+		}
+
+		url := fmt.Sprintf("http://%s:%d/media/%d", serverIP, port, file.ID)
+		durationAttr := ""
+		if track.DurationMs.Valid {
+			durationAttr = fmt.Sprintf(` duration="%s"`, formatDIDLDuration(track.DurationMs.Int64))
+		}
+		didl.WriteString(fmt.Sprintf(`<item id="%d" parentID="%s" restricted="1"><dc:title>%s</dc:title><upnp:class>%s</upnp:class><res protocolInfo="http-get:*:%s:*"%s size="%d">%s</res></item>`,
+			file.ID, xmlEscape(objectID), xmlEscape(file.Filename), getUPnPClass(file.MimeType), file.MimeType, durationAttr, file.Size, xmlEscape(url)))
+		numberReturned++
+	}
+	didl.WriteString(`</DIDL-Lite>`)
+
+	return wrapBrowseResponse(didl.String(), numberReturned, numberReturned, state)
+}
+
+// formatDIDLDuration renders a millisecond duration as the DIDL-Lite
+// "H:MM:SS.mmm" format expected in a <res> element's duration attribute.
+func formatDIDLDuration(ms int64) string {
+	d := time.Duration(ms) * time.Millisecond
+	hours := int(d / time.Hour)
+	minutes := int((d % time.Hour) / time.Minute)
+	seconds := int((d % time.Minute) / time.Second)
+	millis := int((d % time.Second) / time.Millisecond)
+	return fmt.Sprintf("%d:%02d:%02d.%03d", hours, minutes, seconds, millis)
+}
@@ -0,0 +1,233 @@
+package web
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"vuio-go/database"
+	"vuio-go/platform"
+	"vuio-go/state"
+)
+
+// SearchParams holds the arguments of a ContentDirectory Search action.
+type SearchParams struct {
+	ContainerID    string
+	SearchCriteria string
+	StartingIndex  int
+	RequestedCount int
+}
+
+func parseSearchParams(actionXML string) SearchParams {
+	return SearchParams{
+		ContainerID:    getXMLValue(actionXML, "ContainerID"),
+		SearchCriteria: getXMLValue(actionXML, "SearchCriteria"),
+		StartingIndex:  getXMLValueInt(actionXML, "StartingIndex"),
+		RequestedCount: getXMLValueInt(actionXML, "RequestedCount"),
+	}
+}
+
+// searchToken is a lexical token produced by tokenizeSearchCriteria.
+type searchToken struct {
+	kind  string // "word", "string", "star"
+	value string
+}
+
+// tokenizeSearchCriteria splits a SearchCriteria string into words, quoted
+// string literals (backslash-escaped), and the "*" match-all shortcut.
+func tokenizeSearchCriteria(input string) ([]searchToken, error) {
+	var tokens []searchToken
+	i, n := 0, len(input)
+
+	for i < n {
+		c := input[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"':
+			var sb strings.Builder
+			j := i + 1
+			for j < n && input[j] != '"' {
+				if input[j] == '\\' && j+1 < n {
+					sb.WriteByte(input[j+1])
+					j += 2
+					continue
+				}
+				sb.WriteByte(input[j])
+				j++
+			}
+			if j >= n {
+				return nil, fmt.Errorf("unterminated string literal in search criteria")
+			}
+			tokens = append(tokens, searchToken{kind: "string", value: sb.String()})
+			i = j + 1
+		case c == '*':
+			tokens = append(tokens, searchToken{kind: "star", value: "*"})
+			i++
+		default:
+			j := i
+			for j < n && input[j] != ' ' && input[j] != '\t' && input[j] != '\n' && input[j] != '\r' && input[j] != '"' {
+				j++
+			}
+			tokens = append(tokens, searchToken{kind: "word", value: input[i:j]})
+			i = j
+		}
+	}
+
+	return tokens, nil
+}
+
+// searchParser is a small recursive-descent parser for the UPnP SearchCriteria
+// grammar: criteria := predicate (("and"|"or") predicate)*
+type searchParser struct {
+	tokens []searchToken
+	pos    int
+}
+
+// ParseSearchCriteria parses a UPnP SearchCriteria string into a
+// database.SearchCriteria expression tree.
+func ParseSearchCriteria(input string) (database.SearchCriteria, error) {
+	input = strings.TrimSpace(input)
+	if input == "" || input == "*" {
+		return database.SearchCriteria{MatchAll: true}, nil
+	}
+
+	tokens, err := tokenizeSearchCriteria(input)
+	if err != nil {
+		return database.SearchCriteria{}, err
+	}
+
+	p := &searchParser{tokens: tokens}
+	criteria, err := p.parseExpression()
+	if err != nil {
+		return database.SearchCriteria{}, err
+	}
+	if p.pos != len(p.tokens) {
+		return database.SearchCriteria{}, fmt.Errorf("unexpected token %q in search criteria", p.tokens[p.pos].value)
+	}
+
+	return criteria, nil
+}
+
+func (p *searchParser) parseExpression() (database.SearchCriteria, error) {
+	left, err := p.parsePredicate()
+	if err != nil {
+		return database.SearchCriteria{}, err
+	}
+
+	for p.pos < len(p.tokens) {
+		tok := p.tokens[p.pos]
+		if tok.kind != "word" || (tok.value != "and" && tok.value != "or") {
+			break
+		}
+		combinator := tok.value
+		p.pos++
+
+		right, err := p.parsePredicate()
+		if err != nil {
+			return database.SearchCriteria{}, err
+		}
+		prevLeft := left
+		left = database.SearchCriteria{Combinator: combinator, Left: &prevLeft, Right: &right}
+	}
+
+	return left, nil
+}
+
+func (p *searchParser) parsePredicate() (database.SearchCriteria, error) {
+	if p.pos >= len(p.tokens) {
+		return database.SearchCriteria{}, fmt.Errorf("unexpected end of search criteria")
+	}
+
+	if p.tokens[p.pos].kind == "star" {
+		p.pos++
+		return database.SearchCriteria{MatchAll: true}, nil
+	}
+
+	if p.tokens[p.pos].kind != "word" {
+		return database.SearchCriteria{}, fmt.Errorf("expected property name, got %q", p.tokens[p.pos].value)
+	}
+	property := p.tokens[p.pos].value
+	p.pos++
+
+	if p.pos >= len(p.tokens) {
+		return database.SearchCriteria{}, fmt.Errorf("expected operator after %q", property)
+	}
+	operator, err := parseSearchOperator(p.tokens[p.pos].value)
+	if err != nil {
+		return database.SearchCriteria{}, err
+	}
+	p.pos++
+
+	if p.pos >= len(p.tokens) {
+		return database.SearchCriteria{}, fmt.Errorf("expected value after operator for %q", property)
+	}
+	value := p.tokens[p.pos].value
+	p.pos++
+
+	return database.SearchCriteria{
+		Predicate: &database.SearchPredicate{Property: property, Operator: operator, Value: value},
+	}, nil
+}
+
+func parseSearchOperator(s string) (database.SearchOperator, error) {
+	switch s {
+	case "contains":
+		return database.SearchOpContains, nil
+	case "doesNotContain":
+		return database.SearchOpDoesNotContain, nil
+	case "derivedfrom":
+		return database.SearchOpDerivedFrom, nil
+	case "=":
+		return database.SearchOpEquals, nil
+	case "!=":
+		return database.SearchOpNotEquals, nil
+	case "<":
+		return database.SearchOpLess, nil
+	case "<=":
+		return database.SearchOpLessEq, nil
+	case ">":
+		return database.SearchOpGreater, nil
+	case ">=":
+		return database.SearchOpGreaterEq, nil
+	case "exists":
+		return database.SearchOpExists, nil
+	default:
+		return "", fmt.Errorf("unsupported search operator %q", s)
+	}
+}
+
+// generateSearchResponse renders a Search result set as DIDL-Lite, reusing
+// itemDIDL so Search items carry the same metadata/res attributes as Browse.
+func generateSearchResponse(containerID string, files []database.MediaFile, totalMatches int, state *state.AppState, userAgent string) string {
+	var didl strings.Builder
+	didl.WriteString(`<DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:upnp="urn:schemas-upnp-org:metadata-1-0/upnp/" xmlns:dlna="urn:schemas-dlna-org:metadata-1-0/">`)
+
+	serverIP, err := platform.GetPrimaryIP()
+	if err != nil {
+		slog.Error("Could not get primary IP for search response", "error", err)
+		serverIP = "127.0.0.1"
+	}
+	port := state.GetConfig().Server.Port
+
+	for _, file := range files {
+		didl.WriteString(itemDIDL(file, containerID, serverIP, port, state, userAgent))
+	}
+	didl.WriteString(`</DIDL-Lite>`)
+
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
+    <s:Body>
+        <u:SearchResponse xmlns:u="urn:schemas-upnp-org:service:ContentDirectory:1">
+            <Result>%s</Result>
+            <NumberReturned>%d</NumberReturned>
+            <TotalMatches>%d</TotalMatches>
+            <UpdateID>%d</UpdateID>
+        </u:SearchResponse>
+    </s:Body>
+</s:Envelope>`,
+		xmlEscape(didl.String()),
+		len(files),
+		totalMatches,
+		state.GetUpdateID())
+}
@@ -0,0 +1,101 @@
+package web
+
+import (
+	"testing"
+
+	"vuio-go/database"
+)
+
+func TestParseSearchCriteriaMatchAll(t *testing.T) {
+	for _, input := range []string{"", "   ", "*"} {
+		got, err := ParseSearchCriteria(input)
+		if err != nil {
+			t.Fatalf("ParseSearchCriteria(%q): unexpected error: %v", input, err)
+		}
+		if !got.MatchAll {
+			t.Errorf("ParseSearchCriteria(%q) = %+v, want MatchAll", input, got)
+		}
+	}
+}
+
+func TestParseSearchCriteriaSinglePredicate(t *testing.T) {
+	got, err := ParseSearchCriteria(`dc:title contains "Matrix"`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Predicate == nil {
+		t.Fatalf("expected a predicate, got %+v", got)
+	}
+	want := database.SearchPredicate{Property: "dc:title", Operator: database.SearchOpContains, Value: "Matrix"}
+	if *got.Predicate != want {
+		t.Errorf("predicate = %+v, want %+v", *got.Predicate, want)
+	}
+}
+
+func TestParseSearchCriteriaCombinator(t *testing.T) {
+	got, err := ParseSearchCriteria(`dc:title contains "a" and upnp:class derivedfrom "object.item.videoItem"`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Combinator != "and" || got.Left == nil || got.Right == nil {
+		t.Fatalf("expected an 'and' combinator with both operands, got %+v", got)
+	}
+	if got.Left.Predicate.Property != "dc:title" {
+		t.Errorf("left operand property = %q, want dc:title", got.Left.Predicate.Property)
+	}
+	if got.Right.Predicate.Property != "upnp:class" {
+		t.Errorf("right operand property = %q, want upnp:class", got.Right.Predicate.Property)
+	}
+}
+
+func TestParseSearchCriteriaEscapedString(t *testing.T) {
+	got, err := ParseSearchCriteria(`dc:title = "She said \"hi\""`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `She said "hi"`
+	if got.Predicate == nil || got.Predicate.Value != want {
+		t.Errorf("predicate value = %q, want %q", got.Predicate, want)
+	}
+}
+
+func TestParseSearchCriteriaErrors(t *testing.T) {
+	cases := []string{
+		`dc:title contains "unterminated`,
+		`dc:title`,
+		`dc:title nonsense "x"`,
+		`dc:title contains "a" extra`,
+	}
+	for _, input := range cases {
+		if _, err := ParseSearchCriteria(input); err == nil {
+			t.Errorf("ParseSearchCriteria(%q): expected error, got nil", input)
+		}
+	}
+}
+
+func TestParseSearchOperator(t *testing.T) {
+	cases := map[string]database.SearchOperator{
+		"contains":       database.SearchOpContains,
+		"doesNotContain": database.SearchOpDoesNotContain,
+		"derivedfrom":    database.SearchOpDerivedFrom,
+		"=":              database.SearchOpEquals,
+		"!=":             database.SearchOpNotEquals,
+		"<":              database.SearchOpLess,
+		"<=":             database.SearchOpLessEq,
+		">":              database.SearchOpGreater,
+		">=":             database.SearchOpGreaterEq,
+		"exists":         database.SearchOpExists,
+	}
+	for s, want := range cases {
+		got, err := parseSearchOperator(s)
+		if err != nil {
+			t.Errorf("parseSearchOperator(%q): unexpected error: %v", s, err)
+		}
+		if got != want {
+			t.Errorf("parseSearchOperator(%q) = %q, want %q", s, got, want)
+		}
+	}
+	if _, err := parseSearchOperator("nope"); err == nil {
+		t.Error("parseSearchOperator(\"nope\"): expected error, got nil")
+	}
+}
@@ -28,7 +28,14 @@ func NewServer(state *state.AppState) *Server {
 // Start runs the web server.
 func (s *Server) Start(ctx context.Context) error {
 	cfg := s.state.GetConfig()
-	addr := fmt.Sprintf("%s:%d", cfg.Server.Interface, cfg.Server.Port)
+	// "0.0.0.0" only binds IPv4; using the bare port binds the wildcard
+	// address on both families so IPv6 clients can reach the server too,
+	// matching SSDP's dual-stack LOCATION URLs.
+	iface := cfg.Server.Interface
+	if iface == "0.0.0.0" || iface == "" {
+		iface = ""
+	}
+	addr := fmt.Sprintf("%s:%d", iface, cfg.Server.Port)
 	slog.Info("Starting web server", "address", addr)
 
 	s.http = &http.Server{
@@ -66,7 +73,11 @@ func (s *Server) router() http.Handler {
 	r := chi.NewRouter()
 
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
+	// Deliberately not using middleware.RealIP: it rewrites r.RemoteAddr from
+	// X-Forwarded-For/X-Real-IP/True-Client-IP on every request regardless of
+	// who sent them, which would let a direct client spoof its way past
+	// reverseProxyAuthMiddleware's trusted-proxy check below. r.RemoteAddr
+	// stays the genuine TCP peer address for the whole stack.
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.Timeout(60 * time.Second))
@@ -79,12 +90,21 @@ func (s *Server) router() http.Handler {
 		})
 	})
 
+	if auth := authMiddleware(s.state.GetConfig()); auth != nil {
+		r.Use(auth)
+	}
+
 	r.Get("/", rootHandler)
 	r.Get("/description.xml", descriptionHandler)
 	r.Get("/ContentDirectory.xml", contentDirectorySCPDHandler)
 	r.Handle("/control/ContentDirectory", soapHandler(contentDirectoryControlHandler))
 	r.Handle("/event/ContentDirectory", http.HandlerFunc(eventSubscribeHandler))
 	r.Get("/media/{id}", serveMediaHandler)
+	r.Get("/thumb/{id}", thumbnailHandler)
+
+	r.Get("/hls/{id}/index.m3u8", hlsMasterPlaylistHandler)
+	r.Get("/hls/{id}/{rung}/index.m3u8", hlsVariantPlaylistHandler)
+	r.Get("/hls/{id}/{rung}/segment_{n}.ts", hlsSegmentHandler)
 
 	return r
 }
@@ -0,0 +1,102 @@
+package web
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"vuio-go/database"
+	"vuio-go/platform"
+	"vuio-go/state"
+)
+
+// tagBrowseDirs maps the virtual "audio/by-*" container name to the tag
+// name it groups files by.
+var tagBrowseDirs = map[string]string{
+	"by-genre":  "genre",
+	"by-artist": "artist",
+	"by-year":   "year",
+}
+
+// tagBrowseRootDirs are the synthetic sub-containers listed under the
+// "audio" root alongside its real subdirectories.
+var tagBrowseRootDirs = []database.MediaDirectory{
+	{Name: "by-genre"},
+	{Name: "by-artist"},
+	{Name: "by-year"},
+}
+
+// isTagBrowseObjectID reports whether objectID addresses one of the
+// "audio/by-genre", "audio/by-artist" or "audio/by-year" virtual
+// containers (or a value beneath one of them).
+func isTagBrowseObjectID(objectID string) bool {
+	if !strings.HasPrefix(objectID, "audio/") {
+		return false
+	}
+	rest := strings.TrimPrefix(objectID, "audio/")
+	parts := strings.SplitN(rest, "/", 2)
+	_, ok := tagBrowseDirs[parts[0]]
+	return ok
+}
+
+// browseByTag handles Browse requests under the "audio/by-genre",
+// "audio/by-artist" and "audio/by-year" virtual containers, listing known
+// tag values as sub-containers and resolving a specific value to the
+// media files carrying it via the item_tags index.
+func browseByTag(objectID string, state *state.AppState) (string, error) {
+	rest := strings.TrimPrefix(objectID, "audio/")
+	parts := strings.SplitN(rest, "/", 2)
+	tagName, ok := tagBrowseDirs[parts[0]]
+	if !ok {
+		return "", fmt.Errorf("invalid tag ObjectID: %s", objectID)
+	}
+
+	if len(parts) == 1 {
+		values, err := state.DB.ListTagValues(tagName)
+		if err != nil {
+			return "", err
+		}
+		return generateTagValuesResponse(objectID, values, state), nil
+	}
+
+	files, err := state.DB.GetFilesByTag(tagName, parts[1])
+	if err != nil {
+		return "", err
+	}
+	return generateTagFilesResponse(objectID, files, state), nil
+}
+
+func generateTagValuesResponse(objectID string, values []string, state *state.AppState) string {
+	var didl strings.Builder
+	didl.WriteString(`<DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:upnp="urn:schemas-upnp-org:metadata-1-0/upnp/">`)
+
+	for _, value := range values {
+		containerID := fmt.Sprintf("%s/%s", objectID, value)
+		didl.WriteString(fmt.Sprintf(`<container id="%s" parentID="%s" restricted="1"><dc:title>%s</dc:title><upnp:class>object.container</upnp:class></container>`,
+			xmlEscape(containerID), xmlEscape(objectID), xmlEscape(value)))
+	}
+	didl.WriteString(`</DIDL-Lite>`)
+
+	return wrapBrowseResponse(didl.String(), len(values), len(values), state)
+}
+
+func generateTagFilesResponse(objectID string, files []database.MediaFile, state *state.AppState) string {
+	var didl strings.Builder
+	didl.WriteString(`<DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:upnp="urn:schemas-upnp-org:metadata-1-0/upnp/">`)
+
+	serverIP, err := platform.GetPrimaryIP()
+	if err != nil {
+		slog.Error("Could not get primary IP for tag browse response", "error", err)
+		serverIP = "127.0.0.1"
+	}
+	port := state.GetConfig().Server.Port
+
+	for _, file := range files {
+		url := fmt.Sprintf("http://%s:%d/media/%d", serverIP, port, file.ID)
+		didl.WriteString(fmt.Sprintf(`<item id="%d" parentID="%s" restricted="1"><dc:title>%s</dc:title><upnp:class>%s</upnp:class><res protocolInfo="http-get:*:%s:*" size="%d">%s</res></item>`,
+			file.ID, xmlEscape(objectID), xmlEscape(file.Filename), getUPnPClass(file.MimeType), file.MimeType, file.Size, xmlEscape(url)))
+	}
+	didl.WriteString(`</DIDL-Lite>`)
+
+	return wrapBrowseResponse(didl.String(), len(files), len(files), state)
+}
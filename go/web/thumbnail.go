@@ -0,0 +1,47 @@
+package web
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// thumbnailHandler serves a cached JPEG thumbnail for a media item,
+// generating it on first request. Referenced from Browse/Search DIDL-Lite
+// as upnp:albumArtURI.
+func thumbnailHandler(w http.ResponseWriter, r *http.Request) {
+	state := getState(r)
+	if state.Thumbnails == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	mf, err := state.DB.GetFileByID(id)
+	if err != nil {
+		slog.Error("Error getting file from DB", "id", id, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if mf == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	path, err := state.Thumbnails.Thumbnail(mf)
+	if err != nil {
+		slog.Warn("Failed to generate thumbnail", "id", id, "error", err)
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	http.ServeFile(w, r, path)
+}
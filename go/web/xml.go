@@ -6,10 +6,13 @@ import (
 	"log/slog"
 	"strconv"
 	"strings"
+	"time"
 
 	"vuio-go/database"
+	dlnaprofile "vuio-go/dlna/profile"
 	"vuio-go/platform"
 	"vuio-go/state"
+	"vuio-go/transcode"
 )
 
 // xmlEscape escapes characters for XML.
@@ -67,6 +70,51 @@ func generateSCPDXML() string {
                 <argument><name>UpdateID</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_UpdateID</relatedStateVariable></argument>
             </argumentList>
         </action>
+        <action>
+            <name>Search</name>
+            <argumentList>
+                <argument><name>ContainerID</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_ObjectID</relatedStateVariable></argument>
+                <argument><name>SearchCriteria</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_SearchCriteria</relatedStateVariable></argument>
+                <argument><name>Filter</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_Filter</relatedStateVariable></argument>
+                <argument><name>StartingIndex</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_Index</relatedStateVariable></argument>
+                <argument><name>RequestedCount</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_Count</relatedStateVariable></argument>
+                <argument><name>SortCriteria</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_SortCriteria</relatedStateVariable></argument>
+                <argument><name>Result</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_Result</relatedStateVariable></argument>
+                <argument><name>NumberReturned</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_Count</relatedStateVariable></argument>
+                <argument><name>TotalMatches</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_Count</relatedStateVariable></argument>
+                <argument><name>UpdateID</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_UpdateID</relatedStateVariable></argument>
+            </argumentList>
+        </action>
+        <action>
+            <name>GetSearchCapabilities</name>
+            <argumentList>
+                <argument><name>SearchCaps</name><direction>out</direction><relatedStateVariable>SearchCapabilities</relatedStateVariable></argument>
+            </argumentList>
+        </action>
+        <action>
+            <name>GetSortCapabilities</name>
+            <argumentList>
+                <argument><name>SortCaps</name><direction>out</direction><relatedStateVariable>SortCapabilities</relatedStateVariable></argument>
+            </argumentList>
+        </action>
+        <action>
+            <name>GetSortExtensionCapabilities</name>
+            <argumentList>
+                <argument><name>SortExtensionCaps</name><direction>out</direction><relatedStateVariable>SortExtensionCapabilities</relatedStateVariable></argument>
+            </argumentList>
+        </action>
+        <action>
+            <name>GetFeatureList</name>
+            <argumentList>
+                <argument><name>FeatureList</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_Featurelist</relatedStateVariable></argument>
+            </argumentList>
+        </action>
+        <action>
+            <name>GetSystemUpdateID</name>
+            <argumentList>
+                <argument><name>Id</name><direction>out</direction><relatedStateVariable>SystemUpdateID</relatedStateVariable></argument>
+            </argumentList>
+        </action>
     </actionList>
     <serviceStateTable>
         <stateVariable sendEvents="no"><name>A_ARG_TYPE_ObjectID</name><dataType>string</dataType></stateVariable>
@@ -75,18 +123,73 @@ func generateSCPDXML() string {
         <stateVariable sendEvents="no"><name>A_ARG_TYPE_Index</name><dataType>ui4</dataType></stateVariable>
         <stateVariable sendEvents="no"><name>A_ARG_TYPE_Count</name><dataType>ui4</dataType></stateVariable>
         <stateVariable sendEvents="no"><name>A_ARG_TYPE_SortCriteria</name><dataType>string</dataType></stateVariable>
+        <stateVariable sendEvents="no"><name>A_ARG_TYPE_SearchCriteria</name><dataType>string</dataType></stateVariable>
         <stateVariable sendEvents="no"><name>A_ARG_TYPE_Result</name><dataType>string</dataType></stateVariable>
         <stateVariable sendEvents="no"><name>A_ARG_TYPE_UpdateID</name><dataType>ui4</dataType></stateVariable>
+        <stateVariable sendEvents="no"><name>A_ARG_TYPE_Featurelist</name><dataType>string</dataType></stateVariable>
+        <stateVariable sendEvents="no"><name>SearchCapabilities</name><dataType>string</dataType></stateVariable>
+        <stateVariable sendEvents="no"><name>SortCapabilities</name><dataType>string</dataType></stateVariable>
+        <stateVariable sendEvents="no"><name>SortExtensionCapabilities</name><dataType>string</dataType></stateVariable>
         <stateVariable sendEvents="yes"><name>SystemUpdateID</name><dataType>ui4</dataType></stateVariable>
         <stateVariable sendEvents="yes"><name>ContainerUpdateIDs</name><dataType>string</dataType></stateVariable>
     </serviceStateTable>
 </scpd>`
 }
 
+// wrapSimpleResponse wraps a single scalar out-argument in the SOAP envelope
+// used by the capability-query ContentDirectory actions (GetSearchCapabilities
+// and friends), which return one string rather than a DIDL-Lite Result.
+func wrapSimpleResponse(actionName, argName, value string) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
+    <s:Body>
+        <u:%sResponse xmlns:u="urn:schemas-upnp-org:service:ContentDirectory:1">
+            <%s>%s</%s>
+        </u:%sResponse>
+    </s:Body>
+</s:Envelope>`,
+		actionName, argName, xmlEscape(value), argName, actionName)
+}
+
+// generateFeatureListXML returns an empty but well-formed A_ARG_TYPE_Featurelist
+// document; VuIO doesn't advertise any DLNA short-profile features yet.
+func generateFeatureListXML() string {
+	return `<Features xmlns="urn:schemas-upnp-org:av:avs" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="urn:schemas-upnp-org:av:avs http://www.upnp.org/schemas/av/avs.xsd"></Features>`
+}
+
+// generateSOAPFaultXML renders a UPnP ContentDirectory control fault (e.g.
+// errorCode 708 InvalidArgs for a malformed SearchCriteria) in place of a
+// successful action response.
+func generateSOAPFaultXML(errorCode int, description string) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
+    <s:Body>
+        <s:Fault>
+            <faultcode>s:Client</faultcode>
+            <faultstring>UPnPError</faultstring>
+            <detail>
+                <UPnPError xmlns="urn:schemas-upnp-org:control-1-0">
+                    <errorCode>%d</errorCode>
+                    <errorDescription>%s</errorDescription>
+                </UPnPError>
+            </detail>
+        </s:Fault>
+    </s:Body>
+</s:Envelope>`,
+		errorCode, xmlEscape(description))
+}
+
 type BrowseParams struct {
 	ObjectID       string
 	StartingIndex  int
 	RequestedCount int
+	SortCriteria   string
+
+	// Filter is captured but not yet applied: honoring a property list
+	// (and @parentID in particular) needs the persistent container-ID
+	// rework described in a follow-up, not the path-concatenated ObjectID
+	// scheme still in use here.
+	Filter string
 }
 
 func parseBrowseParams(actionXML string) BrowseParams {
@@ -94,18 +197,21 @@ func parseBrowseParams(actionXML string) BrowseParams {
 		ObjectID:       getXMLValue(actionXML, "ObjectID"),
 		StartingIndex:  getXMLValueInt(actionXML, "StartingIndex"),
 		RequestedCount: getXMLValueInt(actionXML, "RequestedCount"),
+		SortCriteria:   getXMLValue(actionXML, "SortCriteria"),
+		Filter:         getXMLValue(actionXML, "Filter"),
 	}
 }
 
-func generateBrowseResponse(objectID string, subdirs []database.MediaDirectory, files []database.MediaFile, totalMatches int, state *state.AppState) string {
+func generateBrowseResponse(objectID string, subdirs []database.MediaDirectory, files []database.MediaFile, totalMatches int, state *state.AppState, userAgent string) string {
 	var didl strings.Builder
-	didl.WriteString(`<DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:upnp="urn:schemas-upnp-org:metadata-1-0/upnp/">`)
+	didl.WriteString(`<DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:upnp="urn:schemas-upnp-org:metadata-1-0/upnp/" xmlns:dlna="urn:schemas-dlna-org:metadata-1-0/">`)
 
 	if objectID == "0" {
 		// Root containers
 		didl.WriteString(`<container id="video" parentID="0" restricted="1"><dc:title>Video</dc:title><upnp:class>object.container</upnp:class></container>`)
 		didl.WriteString(`<container id="audio" parentID="0" restricted="1"><dc:title>Audio</dc:title><upnp:class>object.container</upnp:class></container>`)
 		didl.WriteString(`<container id="image" parentID="0" restricted="1"><dc:title>Image</dc:title><upnp:class>object.container</upnp:class></container>`)
+		didl.WriteString(`<container id="playlists" parentID="0" restricted="1"><dc:title>Playlists</dc:title><upnp:class>object.container</upnp:class></container>`)
 	} else {
 		// Use the subdirs and files passed from the handler
 		serverIP, err := platform.GetPrimaryIP()
@@ -115,17 +221,29 @@ func generateBrowseResponse(objectID string, subdirs []database.MediaDirectory,
 		}
 		port := state.GetConfig().Server.Port
 
+		trimmedParent := strings.TrimRight(objectID, "/")
 		for _, dir := range subdirs {
-			// Ensure containerID is correctly formed, especially for nested paths.
-			// Trim trailing slash from objectID to prevent double slashes like "video//subdir"
-			containerID := fmt.Sprintf("%s/%s", strings.TrimRight(objectID, "/"), dir.Name)
-			didl.WriteString(fmt.Sprintf(`<container id="%s" parentID="%s" restricted="1"><dc:title>%s</dc:title><upnp:class>object.container</upnp:class></container>`,
-				xmlEscape(containerID), xmlEscape(objectID), xmlEscape(dir.Name)))
+			// Trim trailing slash from objectID to prevent double slashes like "video//subdir".
+			// Real folders carry the folders.id as their last path segment so
+			// paths with special characters round-trip cleanly; synthetic
+			// containers (e.g. the "by-genre" tag browse roots) have no
+			// folder row, so fall back to their name.
+			var containerID, childCountAttr string
+			if dir.ID != 0 {
+				containerID = fmt.Sprintf("%s/%d", trimmedParent, dir.ID)
+				if count, err := state.DB.CountChildren(dir.ID); err != nil {
+					slog.Error("Could not count folder children", "folderID", dir.ID, "error", err)
+				} else {
+					childCountAttr = fmt.Sprintf(` childCount="%d"`, count)
+				}
+			} else {
+				containerID = fmt.Sprintf("%s/%s", trimmedParent, dir.Name)
+			}
+			didl.WriteString(fmt.Sprintf(`<container id="%s" parentID="%s" restricted="1"%s><dc:title>%s</dc:title><upnp:class>object.container</upnp:class></container>`,
+				xmlEscape(containerID), xmlEscape(objectID), childCountAttr, xmlEscape(dir.Name)))
 		}
 		for _, file := range files {
-			url := fmt.Sprintf("http://%s:%d/media/%d", serverIP, port, file.ID)
-			didl.WriteString(fmt.Sprintf(`<item id="%d" parentID="%s" restricted="1"><dc:title>%s</dc:title><upnp:class>%s</upnp:class><res protocolInfo="http-get:*:%s:*" size="%d">%s</res></item>`,
-				file.ID, xmlEscape(objectID), xmlEscape(file.Filename), getUPnPClass(file.MimeType), file.MimeType, file.Size, xmlEscape(url)))
+			didl.WriteString(itemDIDL(file, objectID, serverIP, port, state, userAgent))
 		}
 	}
 
@@ -133,12 +251,18 @@ func generateBrowseResponse(objectID string, subdirs []database.MediaDirectory,
 
 	numberReturned := len(subdirs) + len(files)
 	if objectID == "0" {
-		numberReturned = 3
+		numberReturned = 4
 	}
 	if totalMatches == 0 {
 		totalMatches = numberReturned
 	}
 
+	return wrapBrowseResponse(didl.String(), numberReturned, totalMatches, state)
+}
+
+// wrapBrowseResponse wraps a DIDL-Lite document in the SOAP envelope
+// expected for a ContentDirectory BrowseResponse.
+func wrapBrowseResponse(didl string, numberReturned, totalMatches int, state *state.AppState) string {
 	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
 <s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
     <s:Body>
@@ -150,12 +274,106 @@ func generateBrowseResponse(objectID string, subdirs []database.MediaDirectory,
         </u:BrowseResponse>
     </s:Body>
 </s:Envelope>`,
-		xmlEscape(didl.String()),
+		xmlEscape(didl),
 		numberReturned,
 		totalMatches,
 		state.GetUpdateID())
 }
 
+// itemDIDL renders a single DIDL-Lite <item> element for file, shared by
+// Browse and Search so both surface the same metadata and <res> attributes.
+func itemDIDL(file database.MediaFile, parentID, serverIP string, port uint16, state *state.AppState, userAgent string) string {
+	url := fmt.Sprintf("http://%s:%d/media/%d", serverIP, port, file.ID)
+	resElems := fmt.Sprintf(`<res protocolInfo="%s"%s size="%d">%s</res>`,
+		dlnaprofile.ProtocolInfo(file), resAttrs(file), file.Size, xmlEscape(url))
+
+	// When a transcode profile applies and actually changes the delivered
+	// mime type, advertise it as an additional <res> alongside the original
+	// so a renderer that can't play the source format has a fallback to
+	// pick instead of being offered only one, wrong, option.
+	if state.GetConfig().Transcode.Enabled {
+		if profile := transcode.SelectProfile(state.TranscodeProfiles, userAgent, file.MimeType); profile != nil && profile.TargetMime != file.MimeType {
+			resElems += fmt.Sprintf(`<res protocolInfo="%s">%s</res>`, dlnaprofile.TranscodedProtocolInfo(profile.TargetMime), xmlEscape(url))
+		}
+	}
+
+	return fmt.Sprintf(`<item id="%d" parentID="%s" restricted="1"><dc:title>%s</dc:title><upnp:class>%s</upnp:class>%s%s%s</item>`,
+		file.ID, xmlEscape(parentID), xmlEscape(file.Filename), getUPnPClass(file.MimeType), mediaMetadataXML(file), albumArtURI(file, serverIP, port, state), resElems)
+}
+
+// albumArtURI renders the upnp:albumArtURI element pointing at the
+// on-demand thumbnail endpoint, or "" if no thumbnail can be produced for
+// file (thumbnailing disabled, or a mime type thumbnail.Generator doesn't
+// support).
+func albumArtURI(file database.MediaFile, serverIP string, port uint16, state *state.AppState) string {
+	if state.Thumbnails == nil {
+		return ""
+	}
+	if !strings.HasPrefix(file.MimeType, "video/") && !strings.HasPrefix(file.MimeType, "image/") {
+		return ""
+	}
+	url := fmt.Sprintf("http://%s:%d/thumb/%d", serverIP, port, file.ID)
+	return fmt.Sprintf(`<upnp:albumArtURI dlna:profileID="JPEG_TN">%s</upnp:albumArtURI>`, xmlEscape(url))
+}
+
+// mediaMetadataXML renders the upnp:/dc: metadata elements DLNA clients use
+// to browse and sort by artist/album/genre rather than just by folder.
+// Fields that weren't extracted for this file (sql.Null* invalid) are
+// omitted rather than rendered empty.
+func mediaMetadataXML(file database.MediaFile) string {
+	var b strings.Builder
+	if file.Artist.Valid && file.Artist.String != "" {
+		b.WriteString(fmt.Sprintf(`<upnp:artist>%s</upnp:artist><dc:creator>%s</dc:creator>`, xmlEscape(file.Artist.String), xmlEscape(file.Artist.String)))
+	}
+	if file.Album.Valid && file.Album.String != "" {
+		b.WriteString(fmt.Sprintf(`<upnp:album>%s</upnp:album>`, xmlEscape(file.Album.String)))
+	}
+	if file.AlbumArtist.Valid && file.AlbumArtist.String != "" {
+		b.WriteString(fmt.Sprintf(`<upnp:albumArtist>%s</upnp:albumArtist>`, xmlEscape(file.AlbumArtist.String)))
+	}
+	if file.Genre.Valid && file.Genre.String != "" {
+		b.WriteString(fmt.Sprintf(`<upnp:genre>%s</upnp:genre>`, xmlEscape(file.Genre.String)))
+	}
+	if file.TrackNumber.Valid {
+		b.WriteString(fmt.Sprintf(`<upnp:originalTrackNumber>%d</upnp:originalTrackNumber>`, file.TrackNumber.Int32))
+	}
+	if file.Year.Valid {
+		b.WriteString(fmt.Sprintf(`<dc:date>%d</dc:date>`, file.Year.Int32))
+	}
+	return b.String()
+}
+
+// resAttrs renders the optional <res> attributes ffprobe was able to
+// determine: duration (H:MM:SS.mmm, as DLNA expects), resolution, bitrate,
+// and audio channel count.
+func resAttrs(file database.MediaFile) string {
+	var b strings.Builder
+	if file.Duration.Valid {
+		b.WriteString(fmt.Sprintf(` duration="%s"`, formatDLNADuration(file.Duration.Int64)))
+	}
+	if file.Width.Valid && file.Height.Valid {
+		b.WriteString(fmt.Sprintf(` resolution="%dx%d"`, file.Width.Int32, file.Height.Int32))
+	}
+	if file.Bitrate.Valid {
+		b.WriteString(fmt.Sprintf(` bitrate="%d"`, file.Bitrate.Int32*1000/8))
+	}
+	if file.Channels.Valid {
+		b.WriteString(fmt.Sprintf(` nrAudioChannels="%d"`, file.Channels.Int32))
+	}
+	return b.String()
+}
+
+// formatDLNADuration formats a millisecond duration as DLNA's
+// H:MM:SS.mmm res@duration format.
+func formatDLNADuration(durationMs int64) string {
+	d := time.Duration(durationMs) * time.Millisecond
+	hours := int(d.Hours())
+	minutes := int(d.Minutes()) % 60
+	seconds := int(d.Seconds()) % 60
+	millis := int(d.Milliseconds()) % 1000
+	return fmt.Sprintf("%d:%02d:%02d.%03d", hours, minutes, seconds, millis)
+}
+
 func getUPnPClass(mimeType string) string {
 	switch {
 	case strings.HasPrefix(mimeType, "video/"):